@@ -0,0 +1,117 @@
+package main
+
+/*
+	files as diagnostic, for the bundle archive; log only gets the list
+
+	- name: "Xorg"
+	  attach:
+	    - "/var/log/Xorg.0.log"
+	    - "/etc/X11/xorg.conf.d/*.conf"    # glob
+*/
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// larger files: only the end is kept
+const MAX_ATTACH_SIZE = 4 << 20
+
+type Attachment struct {
+	Path      string // on inspected system, redacted as the content
+	Content   string // redacted
+	Size      int64  // of original file
+	Truncated bool
+	Redacted  int
+}
+
+// name in bundle archive
+func (at Attachment) Name() string {
+	return "attachments" + at.Path
+}
+
+func readAttachment(filename string) (content []byte, size int64, truncated bool, err error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, 0, false, err
+	}
+	if info.IsDir() {
+		return nil, 0, false, fmt.Errorf("%s is a directory", filename)
+	}
+	size = info.Size()
+	if size > MAX_ATTACH_SIZE {
+		f.Seek(size-MAX_ATTACH_SIZE, io.SeekStart)
+		truncated = true
+	}
+	content, err = io.ReadAll(io.LimitReader(f, MAX_ATTACH_SIZE))
+	return content, size, truncated, err
+}
+
+// read and redact files, output is the list
+func (a *Action) attachFiles(ctx context.Context) bool {
+	a.Attachments = nil
+	errs := []string{}
+	for _, pattern := range a.Attach {
+		if a.askreply != "" {
+			pattern = strings.ReplaceAll(pattern, "%ASK%", a.askreply)
+		}
+		pattern, err := a.expand(pattern)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		matches, _ := filepath.Glob(rootPath(pattern))
+		if len(matches) < 1 {
+			errs = append(errs, fmt.Sprintf("file not found \"%s\"", pattern))
+			continue
+		}
+		for _, match := range matches {
+			if ctx.Err() != nil {
+				return false
+			}
+			content, size, truncated, err := readAttachment(match)
+			if err != nil {
+				errs = append(errs, err.Error())
+				continue
+			}
+			before := a.Redacted
+			at := Attachment{
+				Path:      a.redact("/" + strings.TrimPrefix(strings.TrimPrefix(match, rootFlag), "/")),
+				Content:   a.redact(string(content)),
+				Size:      size,
+				Truncated: truncated,
+			}
+			at.Redacted = a.Redacted - before
+			a.Attachments = append(a.Attachments, at)
+		}
+	}
+
+	for _, at := range a.Attachments {
+		note := ""
+		if at.Truncated {
+			note = fmt.Sprintf(", last %d KB", MAX_ATTACH_SIZE>>10)
+		}
+		size := fmt.Sprintf("%d B", at.Size)
+		if at.Size >= 1<<10 {
+			size = fmt.Sprintf("%d KB", at.Size>>10)
+		}
+		a.Output += fmt.Sprintf("%s (%d lines, %s%s)\n", at.Path, strings.Count(at.Content, "\n"), size, note)
+	}
+	if len(errs) > 0 {
+		a.Stderr = strings.Join(errs, "\n") + "\n"
+	}
+	if len(a.Attachments) < 1 {
+		a.Status = 1
+		return false
+	}
+	a.Status = 0
+	return true
+}
@@ -0,0 +1,174 @@
+package main
+
+/*
+	one archive for a case: logs.md, report.json, attachments/... and manifest.json
+
+	makelogs -bundle gz       logs.tar.gz
+	makelogs -bundle zst      logs.tar.zst, with zstd command (else gz)
+*/
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+var bundleFlag string = "" // "gz" or "zst"
+
+type ManifestFile struct {
+	Name      string `json:"name"`
+	Size      int    `json:"size"`
+	Sha256    string `json:"sha256"`
+	Action    string `json:"action,omitempty"`
+	Source    string `json:"source,omitempty"` // path on inspected system
+	Redacted  int    `json:"redacted,omitempty"`
+	Truncated bool   `json:"truncated,omitempty"`
+}
+
+type Manifest struct {
+	Config  string         `json:"config"`
+	Version string         `json:"version"`
+	Date    time.Time      `json:"date"`
+	Root    string         `json:"root,omitempty"`
+	Files   []ManifestFile `json:"files"`
+}
+
+type bundleEntry struct {
+	file    ManifestFile
+	content []byte
+}
+
+func newBundleEntry(name string, content []byte) bundleEntry {
+	sum := sha256.Sum256(content)
+	return bundleEntry{
+		file:    ManifestFile{Name: name, Size: len(content), Sha256: hex.EncodeToString(sum[:])},
+		content: content,
+	}
+}
+
+// bundle filename is the log filename with archive extension
+func bundleFilename(logfile string, format string) string {
+	return strings.TrimSuffix(logfile, ".md") + ".tar." + format
+}
+
+func writeBundle(conf *Service, logfile string, format string) (string, error) {
+	if format != "gz" && format != "zst" {
+		return "", fmt.Errorf("unknown bundle format \"%s\"", format)
+	}
+	if format == "zst" {
+		if _, err := exec.LookPath("zstd"); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: zstd not found, bundle in gzip\n", Warning("Warning"))
+			format = "gz"
+		}
+	}
+
+	entries := []bundleEntry{}
+	data, err := os.ReadFile(logfile)
+	if err != nil {
+		return "", err
+	}
+	entries = append(entries, newBundleEntry("logs.md", data))
+	var report bytes.Buffer
+	if err := encodeReport(&report, conf, "json"); err != nil {
+		return "", err
+	}
+	entries = append(entries, newBundleEntry("report.json", report.Bytes()))
+	names := make(map[string]int) // redacted paths can be the same: /home/[**home**]/.bashrc
+	for _, action := range conf.Actions {
+		for _, at := range action.Attachments {
+			name := at.Name()
+			if names[name]++; names[name] > 1 {
+				name = fmt.Sprintf("%s.%d", name, names[name])
+			}
+			entry := newBundleEntry(name, []byte(at.Content))
+			entry.file.Action = action.Name
+			entry.file.Source = at.Path
+			entry.file.Redacted = at.Redacted
+			entry.file.Truncated = at.Truncated
+			entries = append(entries, entry)
+		}
+	}
+
+	manifest := Manifest{Config: conf.Command, Version: conf.Version, Date: time.Now(), Root: rootFlag}
+	for _, entry := range entries {
+		manifest.Files = append(manifest.Files, entry.file)
+	}
+	data, err = json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	entries = append([]bundleEntry{newBundleEntry("manifest.json", data)}, entries...)
+
+	filename := bundleFilename(logfile, format)
+	f, err := os.Create(filename)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	var compressed io.WriteCloser
+	var zstd *exec.Cmd
+	if format == "zst" {
+		zstd = exec.Command("zstd", "-q", "-c")
+		zstd.Stdout = f
+		zstd.Stderr = os.Stderr
+		if compressed, err = zstd.StdinPipe(); err != nil {
+			return "", err
+		}
+		if err := zstd.Start(); err != nil {
+			return "", err
+		}
+	} else {
+		compressed = gzip.NewWriter(f)
+	}
+
+	if err := writeTar(compressed, entries, manifest.Date); err != nil {
+		compressed.Close()
+		if zstd != nil {
+			zstd.Process.Kill()
+			zstd.Wait()
+		}
+		os.Remove(filename)
+		return "", err
+	}
+	if err := compressed.Close(); err != nil {
+		if zstd != nil {
+			zstd.Wait()
+		}
+		os.Remove(filename)
+		return "", err
+	}
+	if zstd != nil {
+		if err := zstd.Wait(); err != nil {
+			return "", fmt.Errorf("zstd: %w", err)
+		}
+	}
+	return filename, nil
+}
+
+func writeTar(w io.Writer, entries []bundleEntry, date time.Time) error {
+	tw := tar.NewWriter(w)
+	for _, entry := range entries {
+		header := &tar.Header{
+			Name:    "makelogs/" + entry.file.Name,
+			Mode:    0644,
+			Size:    int64(len(entry.content)),
+			ModTime: date,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := tw.Write(entry.content); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
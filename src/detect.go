@@ -0,0 +1,221 @@
+package main
+
+/*
+	private values read from the system, replaced exactly (not guessed by regex)
+	with -root, only from files of the mounted system: nmcli and /dev/disk are of the live host
+*/
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// values shorter are too common to replace
+const MIN_SECRET_LEN = 5
+
+// max time of a command to detect values
+const DETECT_TIMEOUT = 3 * time.Second
+
+// rules from system values, before regex rules
+func detectedRules() []*RedactRule {
+	detectors := []struct {
+		name    string
+		replace string
+		values  func() []string
+		words   bool // only whole words, as "mybox" not in "mybox2"
+	}{
+		{"machine-id", "[**machine-id**]", detectMachineID, false},
+		{"serial", "[**serial**]", detectDiskSerials, false},
+		{"uuid", "[**uuid**]", detectUUIDs, false},
+		{"host", "[**host**]", detectHostnames, true},
+		{"ssid", "[**ssid**]", detectSSIDs, false},
+		{"home", "/home/[**home**]", detectHomes, false},
+	}
+	rules := []*RedactRule{}
+	for _, detector := range detectors {
+		values := detector.values()
+		if len(values) < 1 {
+			continue
+		}
+		rule := &RedactRule{Name: detector.name, Replace: detector.replace}
+		if detector.words {
+			rule.re = wordsRegex(values)
+		} else {
+			rule.setLiterals(values)
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// values are longest first
+func wordsRegex(values []string) *regexp.Regexp {
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = regexp.QuoteMeta(value)
+	}
+	return regexp.MustCompile(`\b(?:` + strings.Join(quoted, "|") + `)\b`)
+}
+
+// unique values, longest first so a value is not cut by a shorter one
+func uniqueValues(values []string) []string {
+	set := make(map[string]bool)
+	ret := []string{}
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if len(value) < MIN_SECRET_LEN || set[value] {
+			continue
+		}
+		set[value] = true
+		ret = append(ret, value)
+	}
+	sort.Slice(ret, func(i, j int) bool { return len(ret[i]) > len(ret[j]) })
+	return ret
+}
+
+func readFirstLine(filename string) string {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(strings.SplitN(string(data), "\n", 2)[0])
+}
+
+func detectMachineID() []string {
+	return uniqueValues([]string{
+		readFirstLine(rootPath("/etc/machine-id")),
+		readFirstLine(rootPath("/var/lib/dbus/machine-id")),
+	})
+}
+
+func detectHostnames() []string {
+	values := []string{readFirstLine(rootPath("/etc/hostname"))}
+	if rootFlag == "" {
+		if host, err := os.Hostname(); err == nil {
+			values = append(values, host)
+		}
+	}
+	// short name of "pc.example.org"
+	for _, host := range values {
+		if i := strings.Index(host, "."); i > 0 {
+			values = append(values, host[:i])
+		}
+	}
+	return uniqueValues(values)
+}
+
+// names of saved wifi connections
+func detectSSIDs() []string {
+	if rootFlag != "" {
+		return detectSSIDFiles()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), DETECT_TIMEOUT)
+	defer cancel()
+	out, err := runCommand(ctx, "LANG=C nmcli -t -f NAME,TYPE connection show 2>/dev/null")
+	if err != nil {
+		return nil
+	}
+	values := []string{}
+	for _, line := range strings.Split(string(out), "\n") {
+		i := strings.LastIndex(line, ":")
+		if i > 0 && strings.HasSuffix(line[i+1:], "wireless") {
+			values = append(values, strings.ReplaceAll(line[:i], `\:`, ":"))
+		}
+	}
+	return uniqueValues(values)
+}
+
+// "ssid=" in NetworkManager connection files
+func detectSSIDFiles() []string {
+	matches, _ := filepath.Glob(rootPath("/etc/NetworkManager/system-connections") + "/*")
+	values := []string{}
+	for _, match := range matches {
+		data, err := os.ReadFile(match)
+		if err != nil {
+			continue
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			if strings.HasPrefix(line, "ssid=") {
+				values = append(values, strings.TrimPrefix(line, "ssid="))
+			}
+		}
+	}
+	return uniqueValues(values)
+}
+
+var (
+	partSuffix      = regexp.MustCompile(`-part\d+$`)
+	usbLunSuffix    = regexp.MustCompile(`-\d+:\d+$`) // usb-Vendor_Model_SERIAL-0:0
+	nvmeNsSuffix    = regexp.MustCompile(`_\d{1,2}$`) // nvme-Model_SERIAL_1
+	diskSerialRegex = regexp.MustCompile(`^(?:ata|nvme|usb|scsi)-(?:.*_)?([^_]+)$`)
+)
+
+// serials in /dev/disk/by-id: ata-Model_Name_SERIAL, nvme-eui.0025..., usb-, scsi-
+// other entries as dm-name-vg0-lv_root are not serials
+func serialFromID(name string) string {
+	name = partSuffix.ReplaceAllString(name, "")
+	switch {
+	case strings.HasPrefix(name, "nvme-eui."), strings.HasPrefix(name, "nvme-nvme."):
+		return name[strings.Index(name, ".")+1:]
+	case strings.HasPrefix(name, "usb-"):
+		name = usbLunSuffix.ReplaceAllString(name, "")
+	case strings.HasPrefix(name, "nvme-") && strings.Count(name, "_") > 1:
+		name = nvmeNsSuffix.ReplaceAllString(name, "")
+	}
+	if m := diskSerialRegex.FindStringSubmatch(name); m != nil && strings.Contains(name, "_") {
+		return m[1]
+	}
+	return ""
+}
+
+func detectDiskSerials() []string {
+	if rootFlag != "" {
+		return nil
+	}
+	entries, _ := os.ReadDir("/dev/disk/by-id")
+	values := []string{}
+	for _, entry := range entries {
+		values = append(values, serialFromID(entry.Name()))
+	}
+	return uniqueValues(values)
+}
+
+func detectUUIDs() []string {
+	if rootFlag != "" {
+		return detectFstabUUIDs()
+	}
+	values := []string{}
+	for _, dir := range []string{"/dev/disk/by-uuid", "/dev/disk/by-partuuid"} {
+		entries, _ := os.ReadDir(dir)
+		for _, entry := range entries {
+			values = append(values, entry.Name())
+		}
+	}
+	return uniqueValues(values)
+}
+
+var fstabUUIDRegex = regexp.MustCompile(`(?m)^\s*(?:PART)?UUID=(\S+)`)
+
+// UUID= and PARTUUID= of mounted system
+func detectFstabUUIDs() []string {
+	data, _ := os.ReadFile(rootPath("/etc/fstab"))
+	values := []string{}
+	for _, match := range fstabUUIDRegex.FindAllStringSubmatch(string(data), -1) {
+		values = append(values, strings.Trim(match[1], `"`))
+	}
+	return uniqueValues(values)
+}
+
+// "/home/name" of each user directory
+func detectHomes() []string {
+	matches, _ := filepath.Glob(rootPath("/home") + "/*")
+	values := []string{}
+	for _, match := range matches {
+		values = append(values, "/home/"+filepath.Base(match))
+	}
+	return uniqueValues(values)
+}
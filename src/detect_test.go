@@ -0,0 +1,65 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSerialFromID(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"ata-Samsung_SSD_860_EVO_500GB_S3Z1NB0K123456X", "S3Z1NB0K123456X"},
+		{"ata-Samsung_SSD_860_EVO_500GB_S3Z1NB0K123456X-part2", "S3Z1NB0K123456X"},
+		{"ata-WDC_WD10EZEX-08WN4A0_WD-WCC6Y1234567", "WD-WCC6Y1234567"},
+		{"nvme-Samsung_SSD_980_1TB_S649NF0R123456A", "S649NF0R123456A"},
+		{"nvme-Samsung_SSD_980_1TB_S649NF0R123456A_1", "S649NF0R123456A"},
+		{"nvme-eui.0025385b71b2c3d4", "0025385b71b2c3d4"},
+		{"usb-SanDisk_Cruzer_Blade_4C530001234567891234-0:0", "4C530001234567891234"},
+		{"usb-SanDisk_Cruzer_Blade_4C530001234567891234-0:0-part1", "4C530001234567891234"},
+		{"scsi-SATA_ST1000DM003-1SB1_Z9A1B2C3", "Z9A1B2C3"},
+		// not serials
+		{"dm-name-vg0-lv_root", ""},
+		{"dm-uuid-LVM-abcdef", ""},
+		{"lvm-pv-uuid-abc_def", ""},
+		{"wwn-0x5002538e40a1b2c3", ""},
+		{"md-name-host:0", ""},
+	}
+	for _, tt := range tests {
+		if got := serialFromID(tt.name); got != tt.want {
+			t.Errorf("serialFromID(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWordsRegex(t *testing.T) {
+	re := wordsRegex([]string{"mybox.lan", "mybox"})
+	got := re.ReplaceAllString("mybox mybox2 mybox.lan xmybox mybox-1", "X")
+	if want := "X mybox2 X xmybox X-1"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestDetectWithRoot(t *testing.T) {
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "etc/NetworkManager/system-connections"), 0700)
+	os.WriteFile(filepath.Join(root, "etc/NetworkManager/system-connections/home.nmconnection"),
+		[]byte("[connection]\nid=home\n[wifi]\nssid=MyHomeWifi\n"), 0600)
+	os.WriteFile(filepath.Join(root, "etc/fstab"),
+		[]byte("# /dev/sda2\nUUID=0a1b2c3d-1111-2222-3333-444455556666 / ext4 rw 0 1\nPARTUUID=\"abcdef01-02\" /boot vfat rw 0 2\n"), 0644)
+	rootFlag = root
+	defer func() { rootFlag = "" }()
+
+	if got := strings.Join(detectSSIDs(), " "); got != "MyHomeWifi" {
+		t.Errorf("ssids %q", got)
+	}
+	if got := strings.Join(detectUUIDs(), " "); got != "0a1b2c3d-1111-2222-3333-444455556666 abcdef01-02" {
+		t.Errorf("uuids %q", got)
+	}
+	if got := detectDiskSerials(); len(got) > 0 {
+		t.Errorf("serials of live host %q", got)
+	}
+}
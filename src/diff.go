@@ -0,0 +1,159 @@
+package main
+
+/*
+	line diff, longest common subsequence
+	lines are compared without timestamps and pids, as "Oct 18 03:36:14 host sshd[812]: ..."
+*/
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// max size of lcs table, larger diffs: all old lines removed, all new added
+const DIFF_MAX_CELLS = 4 << 20
+
+var (
+	// syslog, iso 8601 and dmesg timestamps at start of line
+	timestampRegex = regexp.MustCompile(`^(\w{3} [ \d]\d \d\d:\d\d:\d\d|\d{4}-\d\d-\d\d[T ]\d\d:\d\d:\d\d[.,\d]*(Z|[+-]\d\d:?\d\d)?|\[ *\d+\.\d+\])\s*`)
+	pidRegex       = regexp.MustCompile(`\[\d+\]:`)
+)
+
+// line as compared
+func diffKey(line string) string {
+	line = timestampRegex.ReplaceAllString(line, "")
+	return pidRegex.ReplaceAllString(line, "[]:")
+}
+
+// same lines, in any order: lists of packages, mounts... not sorted by the command
+func sameLines(a string, b string) bool {
+	if a == b {
+		return true
+	}
+	keys := func(text string) []string {
+		lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+		for i, line := range lines {
+			lines[i] = diffKey(line)
+		}
+		sort.Strings(lines)
+		return lines
+	}
+	ka, kb := keys(a), keys(b)
+	if len(ka) != len(kb) {
+		return false
+	}
+	for i := range ka {
+		if ka[i] != kb[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// lines prefixed by " ", "-" or "+", unchanged lines as in b
+func diffLines(a []string, b []string) []string {
+	ka := make([]string, len(a))
+	for i, line := range a {
+		ka[i] = diffKey(line)
+	}
+	kb := make([]string, len(b))
+	for j, line := range b {
+		kb[j] = diffKey(line)
+	}
+
+	// common start and end are not in lcs table
+	ret := []string{}
+	start := 0
+	for start < len(a) && start < len(b) && ka[start] == kb[start] {
+		ret = append(ret, " "+b[start])
+		start++
+	}
+	end := 0
+	for end < len(a)-start && end < len(b)-start && ka[len(a)-1-end] == kb[len(b)-1-end] {
+		end++
+	}
+	n, m := len(a)-start-end, len(b)-start-end
+
+	if (n+1)*(m+1) > DIFF_MAX_CELLS {
+		for i := start; i < start+n; i++ {
+			ret = append(ret, "-"+a[i])
+		}
+		for j := start; j < start+m; j++ {
+			ret = append(ret, "+"+b[j])
+		}
+	} else {
+		// lcs[i][j]: length of common subsequence of a[start+i:] and b[start+j:]
+		lcs := make([][]int, n+1)
+		for i := range lcs {
+			lcs[i] = make([]int, m+1)
+		}
+		for i := n - 1; i >= 0; i-- {
+			for j := m - 1; j >= 0; j-- {
+				if ka[start+i] == kb[start+j] {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else if lcs[i+1][j] >= lcs[i][j+1] {
+					lcs[i][j] = lcs[i+1][j]
+				} else {
+					lcs[i][j] = lcs[i][j+1]
+				}
+			}
+		}
+
+		i, j := 0, 0
+		for i < n && j < m {
+			switch {
+			case ka[start+i] == kb[start+j]:
+				ret = append(ret, " "+b[start+j])
+				i++
+				j++
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				ret = append(ret, "-"+a[start+i])
+				i++
+			default:
+				ret = append(ret, "+"+b[start+j])
+				j++
+			}
+		}
+		for ; i < n; i++ {
+			ret = append(ret, "-"+a[start+i])
+		}
+		for ; j < m; j++ {
+			ret = append(ret, "+"+b[start+j])
+		}
+	}
+
+	for j := len(b) - end; j < len(b); j++ {
+		ret = append(ret, " "+b[j])
+	}
+	return ret
+}
+
+// print only changed lines with some context, colored if color
+func printDiff(w io.Writer, a string, b string, context int, color bool) {
+	lines := diffLines(strings.Split(a, "\n"), strings.Split(b, "\n"))
+	last := -1
+	for i, line := range lines {
+		show := false
+		for k := i - context; k <= i+context; k++ {
+			if k >= 0 && k < len(lines) && lines[k][0] != ' ' {
+				show = true
+				break
+			}
+		}
+		if !show {
+			continue
+		}
+		if last >= 0 && i > last+1 {
+			fmt.Fprintln(w, "@@")
+		}
+		last = i
+		if color && line[0] == '-' {
+			line = Warning(line)
+		} else if color && line[0] == '+' {
+			line = Primary(line)
+		}
+		fmt.Fprintln(w, line)
+	}
+}
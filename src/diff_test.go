@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{"same", "a\nb", "a\nb", " a  b"},
+		{"changed", "a\nb\nc", "a\nB\nc", " a -b +B  c"},
+		{"added", "a\nc", "a\nb\nc", " a +b  c"},
+		{"timestamps", "Oct 17 10:00:01 host sshd[12]: fail\nx", "Oct 18 03:36:14 host sshd[812]: fail\ny",
+			" Oct 18 03:36:14 host sshd[812]: fail -x +y"},
+		{"iso and dmesg", "2026-10-17T10:00:01+0200 up\n[    1.234567] usb", "2026-10-18T03:36:14.123+0200 up\n[   12.000001] usb",
+			" 2026-10-18T03:36:14.123+0200 up  [   12.000001] usb"},
+	}
+	for _, tt := range tests {
+		got := strings.Join(diffLines(strings.Split(tt.a, "\n"), strings.Split(tt.b, "\n")), " ")
+		if got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDiffLinesLarge(t *testing.T) {
+	// over DIFF_MAX_CELLS: no lcs table, common start and end kept
+	a, b := []string{"first"}, []string{"first"}
+	for i := 0; i < 3000; i++ {
+		a = append(a, fmt.Sprintf("old %d", i))
+		b = append(b, fmt.Sprintf("new %d", i))
+	}
+	a, b = append(a, "last"), append(b, "last")
+	lines := diffLines(a, b)
+	if len(lines) != 6002 || lines[0] != " first" || lines[1] != "-old 0" || lines[3001] != "+new 0" || lines[6001] != " last" {
+		t.Errorf("got %d lines: %q ... %q", len(lines), lines[:2], lines[len(lines)-1])
+	}
+}
+
+func TestSameLines(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"/ ext4\n/boot vfat\n", "/boot vfat\n/ ext4\n", true}, // mounts in other order
+		{"bash 5.1-1\nzstd 1.5\n", "bash 5.2-1\nzstd 1.5\n", false},
+		{"Oct 17 10:00:01 host kernel: oops\n", "Oct 18 03:36:14 host kernel: oops\n", true},
+		{"a\n", "a\na\n", false},
+	}
+	for _, tt := range tests {
+		if got := sameLines(tt.a, tt.b); got != tt.want {
+			t.Errorf("sameLines(%q, %q) = %v", tt.a, tt.b, got)
+		}
+	}
+}
+
+func TestDiffRuns(t *testing.T) {
+	before := &ServiceReport{Actions: []ActionReport{
+		{Name: "mounts", State: "succeeded", Output: "/ ext4\n/boot vfat\n"},
+		{Name: "journal", State: "succeeded", Output: "Oct 17 10:00:01 host kernel: oops\n"},
+		{Name: "pkgs", State: "succeeded", Output: "bash 5.1-1\n"},
+		{Name: "gone", State: "succeeded"},
+	}}
+	after := &ServiceReport{Actions: []ActionReport{
+		{Name: "mounts", State: "succeeded", Output: "/boot vfat\n/ ext4\n"},
+		{Name: "journal", State: "succeeded", Output: "Oct 18 03:36:14 host kernel: oops\n"},
+		{Name: "pkgs", State: "failed", Status: 1, Output: "bash 5.2-1\n"},
+		{Name: "new", State: "succeeded"},
+	}}
+	got := []string{}
+	for _, d := range diffRuns(before, after) {
+		got = append(got, d.Name+":"+d.Kind)
+	}
+	if want := "pkgs:changed new:added gone:removed"; strings.Join(got, " ") != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -5,56 +5,153 @@ import (
 	"errors"
 	"fmt"
 	"io/fs"
-	"io/ioutil"
 	"log"
 	"os"
 	"path"
 	"path/filepath"
-
-	"gopkg.in/yaml.v3"
+	"sort"
+	"strconv"
+	"strings"
 )
 
 //go:embed yaml/*.yaml
 var fe embed.FS
 
+// filename prefix for files inside the binary
+const EMBEDDED = "embedded:"
+
+// config directories, a file in a later layer overrides the same name in previous ones
+type Layer struct {
+	Name string
+	Dir  string // empty for embedded files
+}
+
+// one config by name, after overlay
+type ConfFile struct {
+	Name     string
+	Filename string
+	Layer    string
+	Shadow   string // note if embedded version is newer or different
+}
+
 type Directory struct {
-	Dir string
+	Dir    string // user directory, for extract
+	Layers []Layer
 }
 
-func (d *Directory) Init(force bool) {
-	configdir, err := os.UserHomeDir()
+func (d *Directory) Init() {
+	home, err := os.UserHomeDir()
 	if err != nil {
 		log.Fatal(err)
 	}
-	// TODO		use /tmp/makelogs ? or not extract is the best ;)
-	d.Dir = configdir + "/.local/share/makelogs/"
-
-	if _, err := os.Stat(d.Dir); errors.Is(err, fs.ErrNotExist) {
-		// extract resources only at first run ... ?
-		// TODO unsecure to have these scripts in home ... extract allways / use only embed files ? or use /var/
-		os.MkdirAll(d.Dir, os.ModePerm)
-		extractConf(d.Dir, fe)
+	configdir := os.Getenv("XDG_CONFIG_HOME")
+	if configdir == "" {
+		configdir = home + "/.config"
+	}
+	d.Dir = configdir + "/makelogs/"
+	d.Layers = []Layer{
+		{Name: "embedded"},
+		{Name: "system", Dir: "/usr/share/makelogs/"},
+		{Name: "admin", Dir: "/etc/makelogs/"},
+		{Name: "user", Dir: d.Dir},
 	}
-	if force {
-		err := os.RemoveAll(d.Dir)
-		if err != nil {
-			fmt.Println(err)
-			os.Exit(4)
+	d.warnOldDir(home + "/.local/share/makelogs/")
+}
+
+// old versions extracted all files in ~/.local/share/makelogs/, not read anymore
+func (d Directory) warnOldDir(olddir string) {
+	matches, _ := filepath.Glob(olddir + "*." + EXTENSION)
+	changed := []string{}
+	for _, filename := range matches {
+		name := filepath.Base(filename)
+		if _, err := os.Stat(d.Dir + name); err == nil {
+			continue
+		}
+		if orig, err := readConfFile(EMBEDDED + name); err == nil {
+			if data, err := os.ReadFile(filename); err == nil && string(data) == string(orig) {
+				continue
+			}
 		}
-		os.MkdirAll(d.Dir, os.ModePerm)
-		extractConf(d.Dir, fe)
+		changed = append(changed, name)
+	}
+	if len(changed) > 0 {
+		fmt.Fprintf(os.Stderr, "%s: %s is not read anymore, move the files you edited (%s) to %s, then remove it\n",
+			Warning("Warning"), olddir, strings.Join(changed, ", "), d.Dir)
 	}
 }
 
-func (d Directory) ForEach(function func(conf *Service), exclude string) {
-	matches, err := filepath.Glob(d.Dir + "/*." + EXTENSION)
+// copy an embedded file in user layer, for edit
+func (d Directory) Extract(name string) (string, error) {
+	name = strings.TrimSuffix(name, "."+EXTENSION) + "." + EXTENSION
+	data, err := readConfFile(EMBEDDED + filepath.Base(name))
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		return "", fmt.Errorf("no embedded config \"%s\", run -l for list", name)
 	}
-	for _, filename := range matches {
-		conf := d.LoadConf(filename)
-		if exclude != conf.Command {
+	filename := d.Dir + filepath.Base(name)
+	if _, err := os.Stat(filename); err == nil {
+		return "", fmt.Errorf("\"%s\" already exists", filename)
+	}
+	if err := os.MkdirAll(d.Dir, 0755); err != nil {
+		return "", err
+	}
+	return filename, os.WriteFile(filename, data, 0644)
+}
+
+// all configs by name, last layer wins
+func (d Directory) Files() []ConfFile {
+	files := make(map[string]ConfFile)
+	for _, layer := range d.Layers {
+		var matches []string
+		if layer.Dir == "" {
+			matches, _ = fs.Glob(fe, EXTENSION+"/*."+EXTENSION)
+			for i := range matches {
+				matches[i] = EMBEDDED + filepath.Base(matches[i])
+			}
+		} else {
+			matches, _ = filepath.Glob(layer.Dir + "*." + EXTENSION)
+		}
+		for _, filename := range matches {
+			name := confName(filename)
+			files[name] = ConfFile{Name: name, Filename: filename, Layer: layer.Name}
+		}
+	}
+
+	ret := make([]ConfFile, 0, len(files))
+	for _, file := range files {
+		if file.Layer != "embedded" {
+			file.Shadow = shadowNote(file.Filename, EMBEDDED+file.Name+"."+EXTENSION)
+		}
+		ret = append(ret, file)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Name < ret[j].Name })
+	return ret
+}
+
+// filename of config by name, empty if not found
+// "sub/name" is relative to each layer, absolute paths and ".." are refused
+func (d Directory) Find(name string) string {
+	name = path.Clean(strings.TrimSuffix(name, "."+EXTENSION) + "." + EXTENSION)
+	if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+		return ""
+	}
+	for i := len(d.Layers) - 1; i >= 0; i-- {
+		filename := d.Layers[i].Dir + name
+		if d.Layers[i].Dir == "" {
+			filename = EMBEDDED + name
+		}
+		if confExists(filename) {
+			return filename
+		}
+	}
+	return ""
+}
+
+func (d Directory) ForEach(function func(conf *Service), exclude string) {
+	for _, file := range d.Files() {
+		conf := d.LoadConf(file.Filename)
+		conf.Layer = file.Layer
+		conf.Shadow = file.Shadow
+		if exclude != conf.Command && !conf.Fragment {
 			function(conf)
 		}
 	}
@@ -68,32 +165,101 @@ func (d Directory) ForEachAll(function func(conf *Service, action *Action)) {
 	}, "searchInAll")
 }
 
-func extractConf(configdir string, dir embed.FS) {
-	myDirFiles, _ := dir.ReadDir(EXTENSION)
-	for _, de := range myDirFiles {
-		f, err := os.Create(configdir + de.Name())
-		if err != nil {
-			log.Fatal(err)
+// read file from disk or from binary
+func readConfFile(filename string) ([]byte, error) {
+	if strings.HasPrefix(filename, EMBEDDED) {
+		return fe.ReadFile(EXTENSION + "/" + filename[len(EMBEDDED):])
+	}
+	return os.ReadFile(filename)
+}
+
+func confExists(filename string) bool {
+	if strings.HasPrefix(filename, EMBEDDED) {
+		_, err := fs.Stat(fe, EXTENSION+"/"+filename[len(EMBEDDED):])
+		return err == nil
+	}
+	_, err := os.Stat(filename)
+	return !errors.Is(err, fs.ErrNotExist)
+}
+
+// "default" from "/etc/makelogs/default.yaml" or "embedded:default.yaml"
+func confName(filename string) string {
+	return strings.TrimSuffix(filepath.Base(strings.TrimPrefix(filename, EMBEDDED)), "."+EXTENSION)
+}
+
+// compare with the embedded file of same name
+func shadowNote(filename string, embedded string) string {
+	if !confExists(embedded) {
+		return ""
+	}
+	data, err := readConfFile(filename)
+	if err != nil {
+		return ""
+	}
+	orig, _ := readConfFile(embedded)
+	if string(data) == string(orig) {
+		return ""
+	}
+	version, origVersion := yamlVersion(data), yamlVersion(orig)
+	if compareVersions(origVersion, version) > 0 {
+		return fmt.Sprintf("shadows newer embedded version %s", origVersion)
+	}
+	return "differs from embedded version"
+}
+
+// value of "version:" without decoding all the file
+func yamlVersion(data []byte) string {
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, "version:") {
+			return strings.Trim(strings.TrimSpace(line[8:]), "\"'")
+		}
+	}
+	return ""
+}
+
+// -1, 0, 1 for "0.0.1" < "0.0.2"
+func compareVersions(a string, b string) int {
+	pa, pb := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		na, nb := 0, 0
+		if i < len(pa) {
+			na, _ = strconv.Atoi(pa[i])
 		}
-		fdata, err := dir.ReadFile(EXTENSION + "/" + de.Name())
-		if err != nil {
-			log.Fatal(err)
+		if i < len(pb) {
+			nb, _ = strconv.Atoi(pb[i])
+		}
+		if na != nb {
+			if na < nb {
+				return -1
+			}
+			return 1
 		}
-		fmt.Fprint(f, string(fdata))
 	}
+	return 0
 }
 
 func (d Directory) LoadConf(filename string) *Service {
-	yfile, err := ioutil.ReadFile(filename)
+	loaded := loadInfo{}
+	node, err := d.loadNode(filename, nil, &loaded)
 	if err != nil {
 		log.Fatal(err)
 	}
-
 	conf := &Service{}
-	err1 := yaml.Unmarshal(yfile, conf)
+	err1 := node.Decode(conf)
 	if err1 != nil {
-		log.Fatal(err1)
+		log.Fatal(fmt.Errorf("%s: %w", filename, err1))
+	}
+	conf.Command = confName(filename)
+	conf.untrusted = d.checkTrust(loaded)
+	for i := range conf.Actions {
+		conf.Actions[i].conf = conf.Command
+		conf.Actions[i].untrusted = conf.untrusted
+	}
+	if err := conf.compileRules(); err != nil {
+		log.Fatal(fmt.Errorf("%s: %w", filename, err))
+	}
+	if err := conf.checkNeeds(); err != nil {
+		log.Fatal(fmt.Errorf("%s: %w", filename, err))
 	}
-	conf.Command = path.Base(filename[:len(filename)-5])
 	return conf
 }
@@ -3,6 +3,6 @@ module makelogs
 go 1.17
 
 require (
-	github.com/acarl005/stripansi v0.0.0-20180116102854-5a71ef0e047d // indirect
-	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b // indirect
+	github.com/acarl005/stripansi v0.0.0-20180116102854-5a71ef0e047d
+	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b
 )
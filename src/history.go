@@ -0,0 +1,168 @@
+package main
+
+/*
+	local history of uploads, to find and withdraw a paste later
+
+	$XDG_STATE_HOME/makelogs/uploads.json, readable only by user (delete tokens)
+	makelogs -uploads         list
+	makelogs -delete 3        remove paste 3 (or by url) if backend supports it
+*/
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+const UPLOADS_FILE = "uploads.json"
+
+type Upload struct {
+	Date        time.Time  `json:"date"`
+	Backend     string     `json:"backend"`
+	URL         string     `json:"url"`
+	DeleteToken string     `json:"delete_token,omitempty"`
+	Sha256      string     `json:"sha256"`
+	File        string     `json:"file"`
+	Deleted     *time.Time `json:"deleted,omitempty"`
+}
+
+// $XDG_STATE_HOME/makelogs/, created if not exists
+func stateDir() string {
+	dir := statePath()
+	os.MkdirAll(dir, 0700)
+	return dir
+}
+
+// as stateDir, without creating it
+func statePath() string {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			log.Fatal(err)
+		}
+		dir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(dir, "makelogs")
+}
+
+func uploadsFilename() string {
+	return filepath.Join(stateDir(), UPLOADS_FILE)
+}
+
+func loadUploads() ([]Upload, error) {
+	uploads := []Upload{}
+	data, err := os.ReadFile(uploadsFilename())
+	if errors.Is(err, fs.ErrNotExist) {
+		return uploads, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(data, &uploads)
+	return uploads, err
+}
+
+func saveUploads(uploads []Upload) error {
+	data, err := json.MarshalIndent(uploads, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(uploadsFilename(), append(data, '\n'), 0600)
+}
+
+func addUpload(result PasteResult, content []byte, filename string) error {
+	uploads, err := loadUploads()
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(content)
+	if abs, err := filepath.Abs(filename); err == nil {
+		filename = abs
+	}
+	uploads = append(uploads, Upload{
+		Date:        time.Now(),
+		Backend:     result.Backend,
+		URL:         result.URL,
+		DeleteToken: result.DeleteToken,
+		Sha256:      hex.EncodeToString(sum[:]),
+		File:        filename,
+	})
+	return saveUploads(uploads)
+}
+
+func uploadsCommand() error {
+	uploads, err := loadUploads()
+	if err != nil {
+		return err
+	}
+	if len(uploads) < 1 {
+		fmt.Println("no upload")
+		return nil
+	}
+	for i, upload := range uploads {
+		state := ""
+		switch {
+		case upload.Deleted != nil:
+			state = Warning("deleted " + upload.Deleted.Format("2006-01-02"))
+		case upload.DeleteToken != "":
+			state = "deletable"
+		}
+		sum := upload.Sha256
+		if len(sum) > 12 { // sha256 may be short or missing in a hand-edited file
+			sum = sum[:12]
+		}
+		fmt.Printf("%3d  %s  %-10s %s  %-12s  %s\n", i+1, upload.Date.Format("2006-01-02 15:04"), upload.Backend, Primary(upload.URL), sum, state)
+	}
+	return nil
+}
+
+// by number in list or by url
+func deleteCommand(ctx context.Context, configdir *Directory, which string) error {
+	uploads, err := loadUploads()
+	if err != nil {
+		return err
+	}
+	index := -1
+	if n, err := strconv.Atoi(which); err == nil && n > 0 && n <= len(uploads) {
+		index = n - 1
+	} else {
+		for i := range uploads {
+			if uploads[i].URL == which {
+				index = i
+			}
+		}
+	}
+	if index < 0 {
+		return fmt.Errorf("upload \"%s\" not found, run -uploads for list", which)
+	}
+	upload := &uploads[index]
+	if upload.Deleted != nil {
+		return fmt.Errorf("%s already deleted", upload.URL)
+	}
+
+	backend, err := configdir.PasteBackend(upload.Backend)
+	if err != nil {
+		return err
+	}
+	deleter, ok := backend.(PasteDeleter)
+	if !ok {
+		return fmt.Errorf("paste backend \"%s\" can not delete", upload.Backend)
+	}
+	err = deleter.Delete(ctx, PasteResult{Backend: upload.Backend, URL: upload.URL, DeleteToken: upload.DeleteToken})
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	upload.Deleted = &now
+	fmt.Printf("%s deleted\n", Primary(upload.URL))
+	return saveUploads(uploads)
+}
@@ -0,0 +1,136 @@
+package main
+
+/*
+	html report, one file without external assets: table of contents,
+	collapsible sections by action, status badges and search
+*/
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+var htmlFlag bool = false
+
+func htmlFilename(logfile string) string {
+	return strings.TrimSuffix(logfile, ".md") + ".html"
+}
+
+func writeHTML(conf *Service, logfile string) (string, error) {
+	filename := htmlFilename(logfile)
+	f, err := os.Create(filename)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return filename, renderHTML(f, conf)
+}
+
+func renderHTML(w io.Writer, conf *Service) error {
+	report := newServiceReport(conf)
+	count := make(map[string]int)
+	for i := range conf.Actions {
+		report.Actions = append(report.Actions, newActionReport(&conf.Actions[i]))
+		count[conf.Actions[i].State()]++
+	}
+	return htmlTemplate.Execute(w, struct {
+		Report     ServiceReport
+		Count      map[string]int
+		Redactions string
+	}{report, count, redactionSummary()})
+}
+
+func htmlDuration(seconds float64) string {
+	d := time.Duration(seconds * float64(time.Second))
+	if d < time.Second {
+		return d.Round(time.Millisecond).String()
+	}
+	return d.Round(100 * time.Millisecond).String()
+}
+
+var htmlTemplate = template.Must(template.New("html").Funcs(template.FuncMap{
+	"duration": htmlDuration,
+	"lines":    func(s string) int { return strings.Count(s, "\n") },
+	"id":       func(i int) string { return fmt.Sprintf("action-%d", i+1) },
+	"mark": func(state string) string {
+		return map[string]string{"succeeded": "✓", "failed": "✗", "skipped": "–"}[state]
+	},
+}).Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Report.Caption}}</title>
+<style>
+body{font-family:sans-serif;margin:0;display:flex;color:#222}
+nav{width:18em;height:100vh;overflow-y:auto;position:sticky;top:0;background:#f6f6f6;padding:1em;box-sizing:border-box;flex-shrink:0}
+nav ul{list-style:none;padding:0;margin:0}nav li{margin:.2em 0}nav a{text-decoration:none;color:#222}
+main{flex:1;padding:1em 2em;min-width:0}
+input{width:100%;padding:.4em;box-sizing:border-box;margin-bottom:1em}
+details{border:1px solid #ddd;border-radius:4px;margin:.5em 0}
+summary{cursor:pointer;padding:.5em;background:#fafafa}
+summary .title{color:#666;margin-left:.5em}
+.meta{float:right;color:#666;font-size:.9em}
+pre{margin:0;padding:.5em;overflow-x:auto;background:#fff;font-size:.9em}
+pre.stderr{background:#fff4f4}
+.badge{display:inline-block;padding:0 .5em;border-radius:.8em;font-size:.8em;color:#fff}
+.succeeded{background:#2a2}.failed{background:#c33}.skipped{background:#999}
+.note{padding:.5em;color:#666}
+.hidden{display:none}
+mark{background:#ff0}
+</style></head><body>
+<nav>
+<input id="search" type="search" placeholder="search">
+<ul>{{range $i, $a := .Report.Actions}}
+<li data-for="{{id $i}}"><span class="badge {{$a.State}}">{{mark $a.State}}</span> <a href="#{{id $i}}">{{$a.Name}}</a></li>{{end}}
+</ul></nav>
+<main>
+<h1>{{.Report.Caption}}</h1>
+<p>{{.Report.Command}} {{.Report.Version}} · {{.Report.Date.Format "2006-01-02 15:04"}} ·
+<span class="badge succeeded">succeeded {{index .Count "succeeded"}}</span>
+<span class="badge failed">failed {{index .Count "failed"}}</span>
+<span class="badge skipped">skipped {{index .Count "skipped"}}</span></p>
+{{if .Redactions}}<p>redacted: {{.Redactions}}</p>{{end}}
+{{range $i, $a := .Report.Actions}}
+<details id="{{id $i}}"{{if eq $a.State "failed"}} open{{end}}>
+<summary><span class="badge {{$a.State}}">{{$a.State}}</span> <b>{{$a.Name}}</b>{{if $a.Title}}<span class="title">{{$a.Title}}</span>{{end}}
+<span class="meta">{{if $a.TimedOut}}timed out · {{else if $a.Canceled}}canceled · {{else if ne $a.State "skipped"}}exit {{$a.Status}} · {{end}}{{lines $a.Output}} lines · {{duration $a.Duration}}</span></summary>
+{{if $a.Skipped}}<div class="note">{{$a.Skipped}}</div>{{end}}
+{{if $a.OnHost}}<div class="note">run on the live host, not on the inspected system</div>{{end}}
+{{if $a.Output}}<pre>{{$a.Output}}</pre>{{end}}
+{{if $a.Stderr}}<pre class="stderr">{{$a.Stderr}}</pre>{{end}}
+</details>{{end}}
+</main>
+<script>
+// show actions with the text, open them and mark the text
+var sections = document.querySelectorAll("main details");
+var saved = [];
+sections.forEach(function (d) {
+  d.querySelectorAll("pre").forEach(function (p) { saved.push([p, p.textContent]); });
+});
+function escape(s) {
+  return s.replace(/&/g, "&amp;").replace(/</g, "&lt;").replace(/>/g, "&gt;");
+}
+document.getElementById("search").addEventListener("input", function () {
+  var q = this.value.toLowerCase();
+  saved.forEach(function (s) {
+    var text = s[1];
+    if (!q) { s[0].textContent = text; return; }
+    var out = "", low = text.toLowerCase(), i = 0, j;
+    while ((j = low.indexOf(q, i)) >= 0) {
+      out += escape(text.slice(i, j)) + "<mark>" + escape(text.slice(j, j + q.length)) + "</mark>";
+      i = j + q.length;
+    }
+    s[0].innerHTML = out + escape(text.slice(i));
+  });
+  sections.forEach(function (d) {
+    var found = !q || d.textContent.toLowerCase().indexOf(q) >= 0;
+    d.classList.toggle("hidden", !found);
+    document.querySelector('nav li[data-for="' + d.id + '"]').classList.toggle("hidden", !found);
+    if (q && found) { d.open = true; }
+  });
+});
+</script>
+</body></html>
+`))
@@ -5,12 +5,11 @@ package main
 */
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"fmt"
-	"log"
 	"math"
 	"os"
-	"os/exec"
 	"regexp"
 	"sort"
 	"strconv"
@@ -19,7 +18,7 @@ import (
 )
 
 type ObjectLog interface {
-	exec() string
+	exec(ctx context.Context) (string, error)
 	init(a *Action)
 }
 
@@ -28,22 +27,106 @@ type ObjectLog interface {
 // ###############
 
 type PkgVer struct {
-	pkgs string
+	pkgs []string
 }
 
 func (p *PkgVer) init(a *Action) {
-	p.pkgs = strings.ToLower(a.Pkgs)
+	p.pkgs = strings.Fields(a.Pkgs)
 }
 
-func (p PkgVer) exec() string {
-	cmd := fmt.Sprintf("LANG=C pacman -Qi %s |awk -F':' '/^Name/ {{n=$2}} /^Ver/ {{print n\": \"$2}}'", p.pkgs)
-	if p.pkgs != "" {
-		out, err := exec.Command("bash", "-c", cmd).Output()
-		if err == nil {
-			return string(out)
+func (p PkgVer) exec(ctx context.Context) (string, error) {
+	// one call by package manager
+	managers := []PackageManager{}
+	pkgs := make(map[string][]string)
+	for _, name := range p.pkgs {
+		pm, pkg := splitPackage(name)
+		if _, ok := pkgs[pm.Name()]; !ok {
+			managers = append(managers, pm)
 		}
+		pkgs[pm.Name()] = append(pkgs[pm.Name()], pkg)
 	}
-	return ""
+	ret := ""
+	for _, pm := range managers {
+		ret += pm.Versions(ctx, pkgs[pm.Name()])
+	}
+	return ret, nil
+}
+
+// ###############
+// Display packages details from pacman local database
+// ###############
+
+type PkgInfo struct {
+	pkgs []string
+}
+
+func (p *PkgInfo) init(a *Action) {
+	p.pkgs = strings.Fields(a.Pkgs)
+}
+
+func (p PkgInfo) exec(ctx context.Context) (string, error) {
+	db, err := getPacmanDB()
+	if err != nil {
+		return "", err
+	}
+	return p.describe(db), nil
+}
+
+func (p PkgInfo) describe(db *PacmanDB) string {
+	ret := ""
+	for _, name := range p.pkgs {
+		pkg, ok := db.Get(name)
+		if !ok {
+			continue
+		}
+		reason := "explicit"
+		if pkg.Reason == 1 {
+			reason = "dependency"
+		}
+		ret += fmt.Sprintf("%s %s\n\tinstalled: %s (%s)\n\tsize: %.1f MiB\n",
+			pkg.Name, pkg.Version, pkg.InstallDate.Format("2006-01-02 15:04"), reason, float64(pkg.Size)/1024/1024)
+		if len(pkg.Groups) > 0 {
+			ret += fmt.Sprintf("\tgroups: %s\n", strings.Join(pkg.Groups, " "))
+		}
+		files := pkg.Files()
+		ret += fmt.Sprintf("\tfiles: %d\n", len(files))
+		for _, file := range files {
+			if strings.HasPrefix(file, "etc/") && !strings.HasSuffix(file, "/") {
+				ret += fmt.Sprintf("\tconfig: /%s\n", file)
+			}
+		}
+	}
+	return ret
+}
+
+// ###############
+// Display last installed/upgraded packages from pacman local database
+// ###############
+
+type PkgRecent struct {
+	count int
+}
+
+func (p *PkgRecent) init(a *Action) {
+	if a.Count == 0 {
+		a.Count = 20
+	}
+	p.count = a.Count
+}
+
+func (p PkgRecent) exec(ctx context.Context) (string, error) {
+	db, err := getPacmanDB()
+	if err != nil {
+		return "", err
+	}
+	ret := ""
+	for i, pkg := range db.Recent() {
+		if i >= p.count {
+			break
+		}
+		ret += fmt.Sprintf("%s %s %s\n", pkg.InstallDate.Format("2006-01-02 15:04"), pkg.Name, pkg.Version)
+	}
+	return ret, nil
 }
 
 // ###############
@@ -76,15 +159,19 @@ func (j *Journald) init(a *Action) {
 	j.level = a.Level
 }
 
-func (j Journald) exec() string {
+func (j Journald) exec(ctx context.Context) (string, error) {
 	const f = "__REALTIME_TIMESTAMP,PRIORITY,_COMM,_UID,MESSAGE,_CMDLINE,SYSLOG_IDENTIFIER"
 	cmd := fmt.Sprintf("journalctl -b0 -p%d -qr -n%d --no-pager --output-fields=\"%s\" -o json", j.level, j.count, f)
+	if rootFlag != "" {
+		// last boot of the mounted system
+		cmd += " -D " + shellQuote(rootPath("/var/log/journal"))
+	}
 	ret := ""
-	out, err := exec.Command("bash", "-c", cmd).Output()
+	out, err := runCommand(ctx, cmd)
 	if err == nil {
 		var dat []JournalType
 		if err := json.Unmarshal([]byte("["+strings.ReplaceAll(string(out), "}\n{", "},\n{")+"]"), &dat); err != nil {
-			panic(err)
+			return "", err
 		}
 		oldentry := ""
 		for _, j := range dat {
@@ -108,9 +195,9 @@ func (j Journald) exec() string {
 				ret = fmt.Sprintf("%s%s %s\n", ret, tm.Format("2006-01-02 15:04:05"), entry)
 			}
 		}
-		return ret
+		return ret, nil
 	}
-	return ""
+	return "", err
 }
 
 type LogsActivity struct {
@@ -138,12 +225,13 @@ func (l *LogsActivity) init(a *Action) {
 	l.regex = regexp.MustCompile(a.Regex)
 }
 
-func (l LogsActivity) exec() (ret string) {
+func (l LogsActivity) exec(ctx context.Context) (ret string, err error) {
 	now := time.Now().AddDate(0, -0, -l.count)
 
-	file, err := os.Open("/var/log/pacman.log")
+	// not found in a mounted system without pacman
+	file, err := os.Open(rootPath("/var/log/pacman.log"))
 	if err != nil {
-		log.Fatal(err)
+		return "", err
 	}
 	defer file.Close()
 
@@ -151,7 +239,7 @@ func (l LogsActivity) exec() (ret string) {
 	maxi := 0
 
 	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
+	for scanner.Scan() && ctx.Err() == nil {
 		line := scanner.Text()
 		if len(line) > 20 && line[0] == '[' {
 			d := line[1:11]
@@ -191,13 +279,17 @@ func (l LogsActivity) exec() (ret string) {
 		n := Primary(fmt.Sprintf("%3d", c))
 		ret += fmt.Sprintf("%s %s %s\n", d, n, strings.Repeat("━", pourcent))
 	}
-	return ret
+	return ret, scanner.Err()
 }
 
 func Objectfactory(name string) (ObjectLog, error) {
 	switch name {
 	case "PkgVer":
 		return new(PkgVer), nil
+	case "PkgInfo":
+		return new(PkgInfo), nil
+	case "PkgRecent":
+		return new(PkgRecent), nil
 	case "Journald":
 		return new(Journald), nil
 	case "LogsActivity":
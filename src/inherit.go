@@ -0,0 +1,252 @@
+package main
+
+/*
+	yaml inheritance, resolved before decoding a Service
+
+	include:                   # all actions of these files are added first
+	  - "common"
+	actions:
+	  - use: "common:lsb-release"   # copy this action, fields below override it
+	    title:
+	      fr: "Infos système"
+*/
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// yaml file name from "include:" or "use:" value
+// a plain name is searched in config layers, else next to including file
+func (d Directory) confFilename(name string, from string) string {
+	if !strings.HasSuffix(name, "."+EXTENSION) {
+		name += "." + EXTENSION
+	}
+	if filepath.IsAbs(name) {
+		return name
+	}
+	if !strings.HasPrefix(name, ".") {
+		if filename := d.Find(name); filename != "" {
+			return filename
+		}
+	}
+	if strings.HasPrefix(from, EMBEDDED) {
+		return EMBEDDED + filepath.Base(name)
+	}
+	return filepath.Join(filepath.Dir(from), name)
+}
+
+// files read by loadNode, and file of action nodes and their keys, for messages
+type loadInfo struct {
+	Files   []string
+	Sources map[*yaml.Node]string
+	Read    []ConfRead // by file, for trust: what was parsed, not the file now
+}
+
+type ConfRead struct {
+	Data []byte
+	Info os.FileInfo // nil if embedded
+}
+
+// keys of a "use:" action come from two files
+func (l *loadInfo) source(action *yaml.Node, filename string, keys bool) {
+	if l == nil {
+		return
+	}
+	if l.Sources == nil {
+		l.Sources = make(map[*yaml.Node]string)
+	}
+	l.Sources[action] = filename
+	for i := 0; keys && i+1 < len(action.Content); i += 2 {
+		l.Sources[action.Content[i]] = filename
+	}
+}
+
+// read yaml file as node, with "include:" and "use:" resolved
+// stack is the list of files currently loading, for cycle detection
+// all files read are added in loaded if not nil
+func (d Directory) loadNode(filename string, stack []string, loaded *loadInfo) (*yaml.Node, error) {
+	if !strings.HasPrefix(filename, EMBEDDED) {
+		if abs, err := filepath.Abs(filename); err == nil {
+			filename = abs
+		}
+	}
+	for i, f := range stack {
+		if f == filename {
+			return nil, fmt.Errorf("include cycle: %s", strings.Join(append(stack[i:], filename), " -> "))
+		}
+	}
+	stack = append(stack, filename)
+
+	var err error
+	read := ConfRead{}
+	if strings.HasPrefix(filename, EMBEDDED) {
+		read.Data, err = readConfFile(filename)
+	} else {
+		read.Data, read.Info, err = readStat(filename)
+	}
+	if err != nil {
+		return nil, err
+	}
+	yfile := read.Data
+	if loaded != nil {
+		loaded.Files = append(loaded.Files, filename)
+		loaded.Read = append(loaded.Read, read)
+	}
+	doc := yaml.Node{}
+	if err := yaml.Unmarshal(yfile, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	if len(doc.Content) < 1 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s: not a yaml mapping", filename)
+	}
+	root := doc.Content[0]
+
+	actions := mappingValue(root, "actions")
+	resolved := []*yaml.Node{}
+
+	if include := mappingValue(root, "include"); include != nil {
+		names := []*yaml.Node{include}
+		if include.Kind == yaml.SequenceNode {
+			names = include.Content
+		}
+		for _, name := range names {
+			node, err := d.loadNode(d.confFilename(name.Value, filename), stack, loaded)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: include \"%s\": %w", filename, name.Line, name.Value, err)
+			}
+			if included := mappingValue(node, "actions"); included != nil {
+				resolved = append(resolved, included.Content...)
+			}
+		}
+		deleteMappingKey(root, "include")
+	}
+
+	if actions != nil {
+		for _, action := range actions.Content {
+			use := mappingValue(action, "use")
+			if use == nil {
+				loaded.source(action, filename, true)
+				resolved = append(resolved, action)
+				continue
+			}
+			base, err := d.useAction(use.Value, filename, stack, loaded, resolved, actions.Content)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: use \"%s\": %w", filename, use.Line, use.Value, err)
+			}
+			deleteMappingKey(action, "use")
+			loaded.source(action, filename, true)
+			merged := mergeNodes(base, action)
+			loaded.source(merged, filename, false)
+			resolved = append(resolved, merged)
+		}
+	}
+
+	if actions == nil && len(resolved) > 0 {
+		root.Content = append(root.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "actions"},
+			&yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"})
+		actions = mappingValue(root, "actions")
+	}
+	if actions != nil {
+		actions.Content = resolved
+	}
+	return root, nil
+}
+
+// action node from "file:action name"
+// in the same file, resolved are the actions above, actions the ones not yet resolved
+func (d Directory) useAction(ref string, from string, stack []string, loaded *loadInfo, resolved []*yaml.Node, actions []*yaml.Node) (*yaml.Node, error) {
+	i := strings.Index(ref, ":")
+	if i < 1 {
+		return nil, errors.New("format is \"file:action name\"")
+	}
+	filename := d.confFilename(ref[:i], from)
+	if !strings.HasPrefix(filename, EMBEDDED) {
+		if abs, err := filepath.Abs(filename); err == nil {
+			filename = abs
+		}
+	}
+	if filename == from {
+		if action := findAction(resolved, ref[i+1:]); action != nil {
+			return action, nil
+		}
+		if action := findAction(actions, ref[i+1:]); action != nil {
+			if mappingValue(action, "use") != nil {
+				return nil, fmt.Errorf("action \"%s\" has also \"use:\", define it before", ref[i+1:])
+			}
+			return action, nil
+		}
+		return nil, fmt.Errorf("action \"%s\" not found", ref[i+1:])
+	}
+
+	node, err := d.loadNode(filename, stack, loaded)
+	if err != nil {
+		return nil, err
+	}
+	if actions := mappingValue(node, "actions"); actions != nil {
+		if action := findAction(actions.Content, ref[i+1:]); action != nil {
+			return action, nil
+		}
+	}
+	return nil, fmt.Errorf("action \"%s\" not found", ref[i+1:])
+}
+
+func findAction(actions []*yaml.Node, name string) *yaml.Node {
+	for _, action := range actions {
+		if value := mappingValue(action, "name"); value != nil && value.Value == name {
+			return action
+		}
+	}
+	return nil
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func deleteMappingKey(node *yaml.Node, key string) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			node.Content = append(node.Content[:i], node.Content[i+2:]...)
+			return
+		}
+	}
+}
+
+// copy of base with fields of override, mappings (as title) are merged by key
+func mergeNodes(base *yaml.Node, override *yaml.Node) *yaml.Node {
+	if base.Kind != yaml.MappingNode || override.Kind != yaml.MappingNode {
+		return override
+	}
+	merged := *base
+	merged.Line, merged.Column = override.Line, override.Column
+	merged.Content = append([]*yaml.Node{}, base.Content...)
+	for i := 0; i+1 < len(override.Content); i += 2 {
+		key, value := override.Content[i], override.Content[i+1]
+		found := false
+		for j := 0; j+1 < len(merged.Content); j += 2 {
+			if merged.Content[j].Value == key.Value {
+				merged.Content[j+1] = mergeNodes(merged.Content[j+1], value)
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged.Content = append(merged.Content, key, value)
+		}
+	}
+	return &merged
+}
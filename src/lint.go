@@ -0,0 +1,315 @@
+package main
+
+/*
+	check yaml configs: makelogs -lint [files]
+*/
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+type Diagnostic struct {
+	File string
+	Line int
+	Msg  string
+}
+
+func (d Diagnostic) String() string {
+	if d.Line < 1 {
+		return fmt.Sprintf("%s: %s", d.File, d.Msg)
+	}
+	return fmt.Sprintf("%s:%d: %s", d.File, d.Line, d.Msg)
+}
+
+// yaml keys accepted for this struct, with type of value
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	keys := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue // unexported, ignored by yaml
+		}
+		name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		keys[name] = field.Type
+	}
+	return keys
+}
+
+// keys not in structs: read before decoding
+var extraKeys = map[reflect.Type][]string{
+	reflect.TypeOf(Service{}): {"include", "sudo"},
+	reflect.TypeOf(Action{}):  {"use"},
+}
+
+// "line 3: cannot unmarshal ..." of yaml.TypeError
+var typeErrorRegex = regexp.MustCompile(`^line (\d+): (.*)$`)
+
+func (d Directory) Lint(filename string) []Diagnostic {
+	diags := []Diagnostic{}
+	seen := make(map[Diagnostic]bool) // an included action can be also used
+	addAt := func(file string, line int, format string, args ...interface{}) {
+		diag := Diagnostic{File: file, Line: line, Msg: fmt.Sprintf(format, args...)}
+		if !seen[diag] {
+			seen[diag] = true
+			diags = append(diags, diag)
+		}
+	}
+	add := func(line int, format string, args ...interface{}) {
+		addAt(filename, line, format, args...)
+	}
+	// decode, a type error does not stop decoding
+	decode := func(node *yaml.Node, file string, out interface{}) bool {
+		err := node.Decode(out)
+		if err == nil {
+			return true
+		}
+		var typeErr *yaml.TypeError
+		if !errors.As(err, &typeErr) {
+			addAt(file, node.Line, "%s", err)
+			return false
+		}
+		for _, e := range typeErr.Errors {
+			if m := typeErrorRegex.FindStringSubmatch(e); m != nil {
+				line, _ := strconv.Atoi(m[1])
+				addAt(file, line, "%s", m[2])
+			} else {
+				addAt(file, node.Line, "%s", e)
+			}
+		}
+		return true
+	}
+
+	yfile, err := readConfFile(filename)
+	if err != nil {
+		add(0, "%s", err)
+		return diags
+	}
+	raw := yaml.Node{}
+	if err := yaml.Unmarshal(yfile, &raw); err != nil {
+		add(0, "%s", err)
+		return diags
+	}
+	if len(raw.Content) < 1 || raw.Content[0].Kind != yaml.MappingNode {
+		add(0, "not a yaml mapping")
+		return diags
+	}
+
+	// unknown keys, in file as written, down to items of lists
+	var checkKeys func(node *yaml.Node, t reflect.Type, where string)
+	checkKeys = func(node *yaml.Node, t reflect.Type, where string) {
+		switch t.Kind() {
+		case reflect.Slice:
+			if node.Kind == yaml.SequenceNode {
+				for _, item := range node.Content {
+					checkKeys(item, t.Elem(), strings.TrimSuffix(where, "s"))
+				}
+			}
+		case reflect.Struct:
+			if node.Kind != yaml.MappingNode {
+				return
+			}
+			fields := yamlFields(t)
+			for _, key := range extraKeys[t] {
+				fields[key] = reflect.TypeOf("")
+			}
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				key := node.Content[i].Value
+				if ft, ok := fields[key]; !ok {
+					add(node.Content[i].Line, "unknown field \"%s\" in %s", key, where)
+				} else {
+					checkKeys(node.Content[i+1], ft, key)
+				}
+			}
+		}
+	}
+	checkKeys(raw.Content[0], reflect.TypeOf(Service{}), "service")
+
+	// include and use
+	loaded := loadInfo{}
+	node, err := d.loadNode(filename, nil, &loaded)
+	if err != nil {
+		add(0, "%s", err)
+		return diags
+	}
+
+	// types, actions one by one for the file of errors: included or "use:"
+	actions := mappingValue(node, "actions")
+	service := *node
+	service.Content = nil
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value != "actions" {
+			service.Content = append(service.Content, node.Content[i], node.Content[i+1])
+		}
+	}
+	conf := Service{}
+	if !decode(&service, filename, &conf) {
+		return diags
+	}
+	sources := []string{}
+	if actions != nil {
+		for _, actionNode := range actions.Content {
+			source := loaded.Sources[actionNode]
+			if source == "" {
+				source = filename
+			}
+			// field by field, for the file of each one
+			action := Action{}
+			if actionNode.Kind != yaml.MappingNode {
+				decode(actionNode, source, &action)
+			} else {
+				for i := 0; i+1 < len(actionNode.Content); i += 2 {
+					field := *actionNode
+					field.Content = actionNode.Content[i : i+2]
+					file, ok := loaded.Sources[actionNode.Content[i]]
+					if !ok {
+						file = source
+					}
+					decode(&field, file, &action)
+				}
+			}
+			conf.Actions = append(conf.Actions, action)
+			sources = append(sources, source)
+		}
+	}
+
+	// each rule, at its line
+	ruleLine := func(parent *yaml.Node, i int) int {
+		if rules := mappingValue(parent, "redact"); rules != nil && i < len(rules.Content) {
+			return rules.Content[i].Line
+		}
+		return parent.Line
+	}
+	for i := range conf.Redact {
+		if err := conf.Redact[i].compile(); err != nil {
+			add(ruleLine(node, i), "%s", err)
+		}
+	}
+	for id := range conf.Actions {
+		for i := range conf.Actions[id].Redact {
+			if err := conf.Actions[id].Redact[i].compile(); err != nil {
+				addAt(sources[id], ruleLine(actions.Content[id], i), "action \"%s\": %s", conf.Actions[id].Name, err)
+			}
+		}
+	}
+	for _, e := range conf.needErrors() {
+		addAt(sources[e.Action], actions.Content[e.Action].Line, "%s", e.Err)
+	}
+
+	names := make(map[string]string)
+	for i, action := range conf.Actions {
+		// diagnostics in the file of the action
+		line := actions.Content[i].Line
+		add := func(line int, format string, args ...interface{}) {
+			addAt(sources[i], line, format, args...)
+		}
+
+		if action.Name == "" {
+			add(line, "action without name")
+		} else if first, ok := names[action.Name]; ok {
+			add(line, "action \"%s\" already defined in %s", action.Name, first)
+		} else {
+			names[action.Name] = fmt.Sprintf("%s:%d", sources[i], line)
+		}
+
+		if action.Command == "" && action.Object == "" && len(action.Attach) < 1 {
+			add(line, "action \"%s\" without command, object or attach", action.Name)
+		}
+		if len(action.Attach) > 0 && (action.Command != "" || action.Object != "") {
+			add(line, "action \"%s\": attach is not used with command or object", action.Name)
+		}
+		if action.Object != "" {
+			if _, err := Objectfactory(action.Object); err != nil {
+				add(line, "action \"%s\": object \"%s\" not present", action.Name, action.Object)
+			}
+		}
+
+		// %ASK% is set by ask: or test:
+		if action.Ask == (llang{}) && action.Test == "" {
+			uses := strings.Contains(action.Command, "%ASK%")
+			for _, req := range action.Requires {
+				uses = uses || strings.Contains(req, "%ASK%")
+			}
+			if uses {
+				add(line, "action \"%s\": %%ASK%% used without ask:", action.Name)
+			}
+		}
+
+		if action.Regex != "" {
+			if _, err := regexp.Compile(action.Regex); err != nil {
+				add(line, "action \"%s\": bad regex: %s", action.Name, err)
+			}
+		}
+
+		for _, req := range action.Requires {
+			if strings.TrimSpace(req) == "" {
+				add(line, "action \"%s\": empty require", action.Name)
+			}
+		}
+	}
+	return diags
+}
+
+// path on disk as given, only a bare name is searched in config layers
+func (d Directory) lintFilename(name string) string {
+	for _, filename := range []string{name, name + "." + EXTENSION} {
+		if info, err := os.Stat(filename); err == nil && !info.IsDir() {
+			return filename
+		}
+	}
+	if strings.Contains(name, "/") {
+		return ""
+	}
+	return d.Find(name)
+}
+
+// lint files or all configs, return count of errors
+func lintCommand(configdir *Directory, names []string) int {
+	filenames := []string{}
+	for _, name := range names {
+		filename := configdir.lintFilename(name)
+		if filename == "" {
+			fmt.Fprintf(os.Stderr, "%s: config not found \"%s\"\n", Danger("Error"), name)
+			return 1
+		}
+		filenames = append(filenames, filename)
+	}
+	if len(filenames) < 1 {
+		for _, file := range configdir.Files() {
+			filenames = append(filenames, file.Filename)
+		}
+	}
+
+	count := 0
+	for _, filename := range filenames {
+		for _, diag := range configdir.Lint(filename) {
+			fmt.Fprintln(os.Stderr, diag)
+			count++
+		}
+		// not a problem of the file, but it will not run as root
+		loaded := loadInfo{}
+		if _, err := configdir.loadNode(filename, nil, &loaded); err == nil {
+			if e := configdir.checkTrust(loaded); e != nil {
+				fmt.Fprintf(os.Stderr, "%s: untrusted config: %s\n", Warning("Warning"), e)
+			}
+		}
+	}
+	if count > 0 {
+		fmt.Fprintf(os.Stderr, "%s: %d problem(s) in %d file(s)\n", Danger("Error"), count, len(filenames))
+	} else {
+		fmt.Printf("%d file(s) %s\n", len(filenames), Primary("ok"))
+	}
+	return count
+}
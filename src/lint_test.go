@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "bad.yaml")
+	os.WriteFile(filename, []byte(`caption: "bad"
+redact:
+  - regex: "("
+  - name: "ok"
+    regex: "a"
+    bogus: 1
+actions:
+  - name: "a"
+    command: "echo"
+    needs: ["b"]
+    title:
+      xx: "y"
+    redact:
+      - literal: "abcdef"
+        nope: true
+      - replace: "x"
+  - name: "b"
+    command: "echo"
+    needs: ["a"]
+  - name: "c"
+    command: "echo"
+    needs: ["zz"]
+`), 0644)
+	d := Directory{Layers: []Layer{{Name: "embedded"}}}
+
+	got := []string{}
+	for _, diag := range d.Lint(filename) {
+		got = append(got, strings.TrimPrefix(diag.String(), filename))
+	}
+	want := []string{
+		`:6: unknown field "bogus" in redact`,
+		`:12: unknown field "xx" in title`,
+		`:15: unknown field "nope" in redact`,
+		":3: redact rule: error parsing regexp: missing closing ): `(`",
+		`:16: action "a": redact rule without regex or literal`,
+		`:20: action "c" needs unknown action "zz"`,
+		`:8: cycle in needs: a -> b -> a`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestLintEmbedded(t *testing.T) {
+	d := Directory{Layers: []Layer{{Name: "embedded"}}}
+	for _, file := range d.Files() {
+		for _, diag := range d.Lint(file.Filename) {
+			t.Error(diag)
+		}
+	}
+}
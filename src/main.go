@@ -2,25 +2,24 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"io/fs"
 	"log"
 	"os"
-	"os/exec"
+	"os/signal"
 	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
-
-	"github.com/acarl005/stripansi"
 )
 
 const (
-	LOGFILE   = "logs.md"
 	EXTENSION = "yaml"
 )
 
@@ -68,8 +67,53 @@ var (
 	GitID     string
 )
 var verboseFlag bool = false
+var reportFlag string = "" // json or ndjson
+
+// stdin lines, read in background: a question is cancelled by Ctrl-C without Enter
+var (
+	stdinLines = make(chan string)
+	stdinOnce  sync.Once
+)
+
+func readAnswer(ctx context.Context) (string, error) {
+	stdinOnce.Do(func() {
+		go func() {
+			reader := bufio.NewReader(os.Stdin)
+			for {
+				line, err := reader.ReadString('\n')
+				if err == nil || line != "" {
+					stdinLines <- strings.TrimSpace(line)
+				}
+				if err != nil {
+					close(stdinLines)
+					return
+				}
+			}
+		}()
+	})
+	select {
+	case <-ctx.Done():
+		fmt.Println("")
+		return "", ctx.Err()
+	case line, ok := <-stdinLines:
+		if !ok {
+			return "", io.EOF
+		}
+		return line, nil
+	}
+}
+
+// exit status of a run, 130 as a shell if interrupted by Ctrl-C
+func runStatus(ctx context.Context) int {
+	if ctx.Err() != nil {
+		fmt.Fprintf(os.Stderr, "%s: interrupted, logs are partial\n", Warning("Warning"))
+		return 130
+	}
+	return 0
+}
 
-func run(conf *Service) {
+func run(ctx context.Context, conf *Service) {
+	requireTrust(conf)
 	//fmt.Printf("%v", conf)
 	fmt.Println("--------")
 	fmt.Printf("%s \t %s \n\n", Secondary(conf.Caption), conf.Version)
@@ -80,29 +124,61 @@ func run(conf *Service) {
 		if ask != "" {
 			fmt.Printf("\n%s\n", Primary("##", conf.Actions[id].Name))
 			fmt.Printf("%s %s ", Primary("##"), Hilite(ask))
-			ret := ""
-			fmt.Scanln(&ret)
-			if len(ret) > 0 && ret[0] != '.' {
-				conf.Actions[id].askreply = strings.TrimSpace(ret)
+			ret, err := readAnswer(ctx)
+			if ctx.Err() != nil {
+				// actions are recorded as canceled
+				break
+			}
+			if err == nil && len(ret) > 0 && ret[0] != '.' {
+				conf.Actions[id].askreply = ret
 			}
 		}
 	}
 
 	var wg sync.WaitGroup
 
+	// an action starts when its needed actions are done
+	needs, missing := conf.needIndexes()
+	done := make([]chan struct{}, len(conf.Actions))
+	for id := range done {
+		done[id] = make(chan struct{})
+	}
 	for id := range conf.Actions {
 		wg.Add(1)
 		go func(id int, wg *sync.WaitGroup) { // can add go for goroutine ?
 			defer wg.Done()
-			conf.Actions[id].exec()
+			defer close(done[id])
+			a := &conf.Actions[id]
+			if len(missing[id]) > 0 {
+				a.skip(fmt.Sprintf("needed action \"%s\" not in this run", missing[id][0]))
+				return
+			}
+			needed := []*Action{}
+			for _, n := range needs[id] {
+				<-done[n]
+				if conf.Actions[n].State() != "succeeded" {
+					a.skip(fmt.Sprintf("needed action \"%s\" %s", conf.Actions[n].Name, conf.Actions[n].State()))
+					return
+				}
+				needed = append(needed, &conf.Actions[n])
+			}
+			a.setVars(needed)
+			a.exec(ctx, conf.timeout(a))
 		}(id, &wg)
 	}
 	wg.Wait()
+	conf.applyBudget(conf.budget())
 }
 
 func displayShort(conf *Service) {
 	fmt.Println(" ")
-	fmt.Printf("%s \t%s \t%s", Primary(conf.Command), Info(conf.Caption), Info(conf.Version))
+	fmt.Printf("%s \t%s \t%s \t[%s]", Primary(conf.Command), Info(conf.Caption), Info(conf.Version), conf.Layer)
+	if conf.Shadow != "" {
+		fmt.Printf(" %s", Warning(conf.Shadow))
+	}
+	if conf.untrusted != nil {
+		fmt.Printf(" %s", Danger("untrusted: "+conf.untrusted.Error()))
+	}
 
 	for _, action := range conf.Actions {
 		fmt.Printf("\n\t%-35s %s ", action.Name, Info(action.Titles.GetText()))
@@ -111,39 +187,120 @@ func displayShort(conf *Service) {
 }
 
 func display(conf *Service, verbose bool) {
-	f, err := os.Create(LOGFILE)
+	logfile := logFilename(conf)
+	f, err := os.Create(logfile)
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer f.Close()
-	fmt.Fprintf(f, "### %s\n", conf.Caption)
+	if err := linkLatest(logfile); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", Warning("Warning"), err)
+	}
+	if outputFlag == "" {
+		pruneLogs(conf)
+	}
+	(&MarkdownRenderer{}).Render(f, conf)
 
 	for _, action := range conf.Actions {
 		if action.Output != "" {
 			fmt.Printf("%s\n%s\n", action, action.Output)
-			fmt.Fprintf(f, "\n:: %s\n```\n%v```\n", action.Name, stripansi.Strip(action.Output))
+		} else if verbose {
+			fmt.Fprintf(os.Stderr, "%s: Nothing for %s\n", Warning("Warning"), action.Name)
+		}
+	}
+	fmt.Printf("\nOutput file : %s\n", Primary(logfile))
+
+	if pseudoFlag && pseudoMapFlag != "" {
+		if err := pseudonyms.save(pseudoMapFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", Danger("Error"), err)
 		} else {
-			if verbose {
-				fmt.Fprintf(os.Stderr, "%s: Nothing for %s\n", Warning("Warning"), action.Name)
-			}
+			fmt.Printf("Mapping file: %s (keep it local, do not send)\n", Primary(pseudoMapFlag))
 		}
 	}
-	fmt.Printf("\nOutput file : %s\n", Primary(LOGFILE))
+
+	if err := saveRun(conf); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: run history: %s\n", Warning("Warning"), err)
+	}
+
+	if reportFlag != "" {
+		filename, err := writeReport(conf, logfile, reportFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", Danger("Error"), err)
+			return
+		}
+		fmt.Printf("Report file : %s\n", Primary(filename))
+	}
+
+	if formatFlag != "" {
+		filename, err := writeRendered(conf, logfile, formatFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", Danger("Error"), err)
+		} else {
+			fmt.Printf("Forum file  : %s\n", Primary(filename))
+		}
+	}
+
+	if htmlFlag {
+		filename, err := writeHTML(conf, logfile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", Danger("Error"), err)
+		} else {
+			fmt.Printf("HTML file   : %s\n", Primary(filename))
+		}
+	}
+
+	if bundleFlag != "" {
+		filename, err := writeBundle(conf, logfile, bundleFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", Danger("Error"), err)
+			return
+		}
+		fmt.Printf("Bundle file : %s\n", Primary(filename))
+	}
+}
+
+// markdown table of succeeded/failed/skipped actions
+func displaySummary(f io.Writer, conf *Service) {
+	count := make(map[string]int)
+	for _, action := range conf.Actions {
+		count[action.State()]++
+	}
+	fmt.Fprintf(f, "\nsucceeded: %d, failed: %d, skipped: %d\n\n", count["succeeded"], count["failed"], count["skipped"])
+	fmt.Fprintln(f, "| action | state | exit | duration | reason |")
+	fmt.Fprintln(f, "|---|---|---|---|---|")
+	for _, action := range conf.Actions {
+		reason := action.Skipped
+		if action.TimedOut {
+			reason = "timed out"
+		}
+		if action.Canceled {
+			reason = "canceled"
+		}
+		if action.OnHost && reason == "" {
+			reason = "live host, not " + rootFlag
+		}
+		fmt.Fprintf(f, "| %s | %s | %d | %s | %s |\n",
+			action.Name, action.State(), action.Status, action.Duration.Round(time.Millisecond), strings.ReplaceAll(reason, "|", "\\|"))
+	}
 }
 
-func searchCommand(search string, configdir *Directory) {
+func searchCommand(ctx context.Context, search string, configdir *Directory) {
 	fmt.Printf("Search: \"%s\"\n", Secondary(search))
 	verboseFlag = true
-	results := Service{Caption: search}
+	results := Service{Caption: search, Command: "search"}
 	i := 0
 	r := strings.ReplaceAll(search, " ", "|")
 	r = strings.ReplaceAll(r, "+", ".*")
 	var validID = regexp.MustCompile(r)
+	all := make(map[string]Action)
 	configdir.ForEachAll(func(conf *Service, action *Action) {
+		all[action.key()] = *action
 		strf := strings.ToLower(action.Name + " " + action.Titles.GetText() + " " + action.Command)
 		if validID.MatchString(strf) {
 			i++
 			action.Id = i
+			action.Status = -1
+			action.Skipped = "not selected"
 			results.Actions = append(results.Actions, *action)
 		}
 	})
@@ -158,15 +315,35 @@ func searchCommand(search string, configdir *Directory) {
 	if len(results.Actions) > 0 {
 		fmt.Printf("Command to run ? (1..%d) ", len(results.Actions))
 
-		scanner := bufio.NewScanner(os.Stdin)
-		if scanner.Scan() {
+		if answer, err := readAnswer(ctx); err == nil {
 			fmt.Println("")
-			for _, number := range strings.Fields(scanner.Text()) {
+			// selected actions and their needs run as a config
+			selected := Service{Caption: search, Command: "search"}
+			ids := []int{}
+			for _, number := range strings.Fields(answer) {
 				id, err := strconv.Atoi(number)
-				if err != nil || id < 1 || id > len(results.Actions) {
+				if err != nil || id < 1 || id > len(results.Actions) || results.Actions[id-1].Skipped != "not selected" {
 					continue
 				}
-				results.Actions[id-1].exec()
+				results.Actions[id-1].Skipped = ""
+				selected.Actions = append(selected.Actions, results.Actions[id-1])
+				ids = append(ids, id-1)
+			}
+			selected.addNeeded(all)
+			run(ctx, &selected)
+			for k, id := range ids {
+				results.Actions[id] = selected.Actions[k]
+			}
+			found := make(map[string]int)
+			for id := range results.Actions {
+				found[results.Actions[id].key()] = id
+			}
+			for _, action := range selected.Actions[len(ids):] {
+				if id, ok := found[action.key()]; ok {
+					results.Actions[id] = action
+				} else {
+					results.Actions = append(results.Actions, action)
+				}
 			}
 
 			display(&results, false)
@@ -174,62 +351,98 @@ func searchCommand(search string, configdir *Directory) {
 	}
 }
 
-func sendToClound(logfile string) {
+func sendToClound(ctx context.Context, logfile string, configdir *Directory) {
 	if _, err := os.Stat(logfile); errors.Is(err, fs.ErrNotExist) {
 		fmt.Fprintf(os.Stderr, "%s: file not found \"%s\"\n", Danger("Error"), logfile)
 		os.Exit(1)
 	}
-	fmt.Printf("! Read log \"%s\" before send this file on web\n", logfile)
+	fmt.Printf("! Review log \"%s\" before send this file on web\n", logfile)
+	interrupted := func() {
+		fmt.Fprintf(os.Stderr, "%s: interrupted, nothing sent\n", Warning("Warning"))
+		os.Exit(130)
+	}
+	if !reviewLog(ctx, logfile) {
+		if ctx.Err() != nil {
+			interrupted()
+		}
+		os.Exit(0)
+	}
 	fmt.Println("Send ? (y/N)")
-	var input string
-	_, err := fmt.Scanln(&input)
+	input, err := readAnswer(ctx)
+	if ctx.Err() != nil {
+		interrupted()
+	}
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(127)
 	}
 
-	out := ""
-	if strings.ToUpper(input) == "Y" {
+	if strings.ToUpper(input) != "Y" {
+		return
+	}
 
-		cloud := func(name string, url string) (string, error) {
-			cmd := fmt.Sprintf("cat '%s' | curl -s -F %s", logfile, url)
-			o, e := exec.Command("bash", "-c", cmd).CombinedOutput()
-			if e != nil {
-				return "", fmt.Errorf("error %s : %s - %s", name, string(o), e)
-			} else {
-				fmt.Printf("\n:: cloud Url is : %s\n", Primary(string(o)))
-				return string(o), nil
-			}
+	content, err := os.ReadFile(logfile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", Danger("Error"), err)
+		os.Exit(1)
+	}
+	backends, err := configdir.PasteBackends(encryptFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", Danger("Error"), err)
+		os.Exit(1)
+	}
+	result, err := uploadContent(ctx, backends, content, logfile)
+	if err != nil && encryptFlag && ctx.Err() == nil {
+		// plain upload only if user agrees
+		fmt.Fprintf(os.Stderr, "%s: %s\n", Warning("Warning"), err)
+		fmt.Println("Encrypted upload failed, send without encryption ? (y/N)")
+		input, _ = readAnswer(ctx)
+		if ctx.Err() != nil {
+			interrupted()
 		}
-
-		out, err = cloud("ix.io", "'f:1=<-' http://ix.io")
-		if err != nil {
-			fmt.Fprintln(os.Stderr, err)
-			out, err = cloud("sprunge", "'sprunge=<-' http://sprunge.us?md")
-			if err != nil {
-				fmt.Fprintln(os.Stderr, err)
-				os.Exit(1)
-			}
+		if strings.ToUpper(input) != "Y" {
+			os.Exit(1)
 		}
-
-		if out != "" {
-			f, err := os.OpenFile(logfile, os.O_APPEND|os.O_WRONLY, 0644)
-			if err == nil {
-				defer f.Close()
-				fmt.Fprintf(f, "\n-----\n\n %s\n", out)
-			}
+		pasteFlag = ""
+		if backends, err = configdir.PasteBackends(false); err == nil {
+			result, err = uploadContent(ctx, backends, content, logfile)
 		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", Danger("Error"), err)
+		os.Exit(1)
+	}
+	fmt.Printf("\n:: cloud Url is : %s\n", Primary(result.URL))
+	if err := addUpload(result, content, logfile); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: upload history: %s\n", Warning("Warning"), err)
+	}
+	if result.DeleteToken != "" {
+		fmt.Println("Can be deleted with -delete, see -uploads")
+	}
 
-		os.Exit(0)
+	f, err := os.OpenFile(logfile, os.O_APPEND|os.O_WRONLY, 0644)
+	if err == nil {
+		defer f.Close()
+		fmt.Fprintf(f, "\n-----\n\n %s\n", result.URL)
 	}
 }
 
 func main() {
+	// Ctrl-C kills running commands, logs are still written
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
 	var configDir Directory = Directory{}
-	configDir.Init(false)
+	configDir.Init()
 
 	args := os.Args[1:]
-	filename := configDir.Dir + "default." + EXTENSION
+	filename := configDir.Find("default")
+	if len(args) > 0 && args[0] == "serve" {
+		os.Exit(serveCommand(ctx, args[1:]))
+	}
+	if len(args) > 0 && args[0] == "diff" {
+		os.Exit(diffCommand(args[1:]))
+	}
 	if len(args) > 0 {
 		if args[0][0] == '-' {
 			// command or option
@@ -239,12 +452,84 @@ func main() {
 			findCmd := flag.Bool("f", false, "Find/run command")
 			lrlistCmd := flag.Bool("lr", false, "List all command for Run")
 			rlistCmd := flag.Bool("r", false, "Run commands")
-			extractCmd := flag.Bool("e", false, "Extract yaml files")
+			extractCmd := flag.Bool("e", false, "Extract yaml files to edit, as: -e wifi")
+			lintCmd := flag.Bool("lint", false, "Check yaml files")
+			unmapCmd := flag.String("unmap", "", "Replace tokens by values with this mapping file")
+			uploadsCmd := flag.Bool("uploads", false, "List past uploads")
+			deleteCmd := flag.String("delete", "", "Delete a past upload, by number or url")
+			flag.StringVar(&pasteFlag, "paste", "", "Paste backends to try, as: 0x0,sprunge")
+			flag.StringVar(&proxyFlag, "proxy", "", "Proxy url for upload")
+			flag.BoolVar(&encryptFlag, "encrypt", false, "Encrypt log before upload (PrivateBin)")
+			flag.StringVar(&expireFlag, "expire", "", "Encrypted paste expiration: 5min, 1hour, 1day, 1week, 1month, never")
+			flag.BoolVar(&burnFlag, "burn", false, "Encrypted paste deleted after first read")
+			flag.BoolVar(&pseudoFlag, "pseudo", false, "Replace each private value by a stable token")
+			flag.StringVar(&pseudoMapFlag, "map", "", "Save tokens of -pseudo in this local file")
 			flag.BoolVar(&verboseFlag, "v", false, "verbose")
+			flag.StringVar(&outputFlag, "o", "", "Log file, or directory (default in $XDG_STATE_HOME/makelogs/)")
+			flag.StringVar(&reportFlag, "report", "", "Write also a report: json or ndjson")
+			flag.IntVar(&budgetFlag, "budget", 0, "Max bytes of all outputs, large ones are cut in the middle")
+			flag.StringVar(&formatFlag, "format", "", "Write also for forums: discourse, bbcode or text")
+			flag.IntVar(&limitFlag, "limit", 0, "Max characters of a forum post (default from -format)")
+			flag.BoolVar(&htmlFlag, "html", false, "Write also a html report")
+			flag.StringVar(&bundleFlag, "bundle", "", "Write also an archive with report and attached files: gz or zst")
+			flag.StringVar(&pkgFlag, "pm", "", "Package manager: pacman, dpkg, rpm or flatpak (default from /etc/os-release)")
+			flag.BoolVar(&trustFlag, "trust", false, "Run as root configs not owned by root")
+			flag.StringVar(&rootFlag, "root", "", "Mounted system to inspect, as /mnt")
+			flag.StringVar(&pacmanDBFlag, "dbpath", PACMAN_DB, "Pacman local database directory")
+			flag.IntVar(&timeoutFlag, "timeout", DEFAULT_TIMEOUT, "Default timeout by action, in seconds")
 			flag.Parse()
 
+			if rootFlag != "" {
+				if info, err := os.Stat(rootFlag); err != nil || !info.IsDir() {
+					fmt.Fprintf(os.Stderr, "%s: root directory not found \"%s\"\n", Danger("Error"), rootFlag)
+					os.Exit(1)
+				}
+			}
+
 			if *extractCmd {
-				configDir.Init(true)
+				if flag.NArg() < 1 {
+					fmt.Fprintf(os.Stderr, "%s: config name to extract missing, run -l for list\n", Danger("Error"))
+					os.Exit(1)
+				}
+				for _, name := range flag.Args() {
+					filename, err := configDir.Extract(name)
+					if err != nil {
+						fmt.Fprintf(os.Stderr, "%s: %s\n", Danger("Error"), err)
+						os.Exit(1)
+					}
+					fmt.Printf("yaml file extracted in %s\n", Primary(filename))
+				}
+				os.Exit(0)
+			}
+
+			if *lintCmd {
+				if lintCommand(&configDir, flag.Args()) > 0 {
+					os.Exit(1)
+				}
+				os.Exit(0)
+			}
+
+			if *unmapCmd != "" {
+				if err := unmapCommand(*unmapCmd, flag.Arg(0)); err != nil {
+					fmt.Fprintf(os.Stderr, "%s: %s\n", Danger("Error"), err)
+					os.Exit(1)
+				}
+				os.Exit(0)
+			}
+
+			if *uploadsCmd {
+				if err := uploadsCommand(); err != nil {
+					fmt.Fprintf(os.Stderr, "%s: %s\n", Danger("Error"), err)
+					os.Exit(1)
+				}
+				os.Exit(0)
+			}
+
+			if *deleteCmd != "" {
+				if err := deleteCommand(ctx, &configDir, *deleteCmd); err != nil {
+					fmt.Fprintf(os.Stderr, "%s: %s\n", Danger("Error"), err)
+					os.Exit(1)
+				}
 				os.Exit(0)
 			}
 
@@ -257,8 +542,20 @@ func main() {
 				fmt.Printf("   ./%s    # load default\n", cmd)
 				fmt.Printf("   ./%s wifi\n", cmd)
 				fmt.Printf("   ./%s disk\n", cmd)
-				fmt.Println("\nREAD/Edit result file:", Hilite(LOGFILE))
+				fmt.Println("\nREAD/Edit result file:", Hilite(filepath.Join(statePath(), LATEST)), "(or -o file)")
+				fmt.Printf("Machine-readable report: \"./%s -report json wifi\"\n", cmd)
+				fmt.Printf("Ready to paste in a forum post: \"./%s -format discourse\" (or bbcode, text)\n", cmd)
+				fmt.Printf("Report to read in a browser: \"./%s -html\"\n", cmd)
+				fmt.Printf("All in one archive, with attached files: \"./%s -bundle zst\"\n", cmd)
+				fmt.Printf("What changed since last run: \"%s diff wifi\"\n", cmd)
+				fmt.Printf("Check custom yaml files: \"./%s -lint my.yaml\"\n", cmd)
+				fmt.Printf("Stable tokens for private values: \"./%s -pseudo -map my.map wifi\", then \"./%s -unmap my.map reply.txt\"\n", cmd, cmd)
+				fmt.Printf("As root, custom yaml files must be owned by root or listed in %s\n", TRUST_MANIFEST)
 				fmt.Printf("\nSend this file to cloud : \"./%s -s\"\n", Hilite(cmd))
+				fmt.Printf("Self-hosted receiver for uploads: \"%s serve -listen :8080 -dir /var/lib/makelogs\"\n", cmd)
+				fmt.Printf("Encrypted, only readable with the link: \"./%s -s -encrypt -expire 1day -burn\"\n", cmd)
+				fmt.Printf("Past uploads: \"./%s -uploads\", withdraw one: \"./%s -delete 2\"\n", cmd, cmd)
+				fmt.Printf("Paste services are tried in order, custom ones in <config dir>/paste/backends.yaml: \"./%s -s -paste sprunge,0x0\"\n", cmd)
 				os.Exit(0)
 			}
 
@@ -288,13 +585,15 @@ func main() {
 			if *rlistCmd {
 				// -r  "default:memory_(base_10)" pacman:arch
 
-				results := Service{Caption: "My logs"}
+				results := Service{Caption: "My logs", Command: "run"}
 				args = flag.Args()
+				all := make(map[string]Action)
 
 				fmt.Println(args)
 				configDir.ForEach(func(conf *Service) {
 					s := conf.Command
 					for _, action := range conf.Actions {
+						all[action.key()] = action
 						canr := fmt.Sprintf("%s:%s", s, strings.ReplaceAll(action.Name, " ", "_"))
 						for _, v := range args {
 							if canr == v {
@@ -306,10 +605,13 @@ func main() {
 
 					}
 				}, "*")
+				for _, key := range results.addNeeded(all) {
+					fmt.Printf("\n %s (needed)", strings.ReplaceAll(key, " ", "_"))
+				}
 				fmt.Println("")
-				run(&results)
+				run(ctx, &results)
 				display(&results, true)
-				os.Exit(0)
+				os.Exit(runStatus(ctx))
 			}
 
 			if *findCmd {
@@ -320,12 +622,24 @@ func main() {
 				if len(search) < 3 {
 					os.Exit(127)
 				}
-				searchCommand(search, &configDir)
-				os.Exit(0)
+				searchCommand(ctx, search, &configDir)
+				os.Exit(runStatus(ctx))
 			}
 
 			if *sendCmd {
-				sendToClound(LOGFILE)
+				// -s [file], else -o, else latest log
+				logfile := flag.Arg(0)
+				if logfile == "" {
+					logfile = outputFlag
+				}
+				if logfile == "" {
+					logfile = latestLog()
+				}
+				if logfile == "" {
+					fmt.Fprintf(os.Stderr, "%s: no log found, run a config first\n", Danger("Error"))
+					os.Exit(1)
+				}
+				sendToClound(ctx, logfile, &configDir)
 				os.Exit(0)
 			}
 
@@ -343,7 +657,11 @@ func main() {
 				filename = pwd + "/" + filename
 			}
 			if !strings.HasPrefix(filename, "/") {
-				filename = configDir.Dir + "/" + filename
+				filename = configDir.Find(filename)
+				if filename == "" {
+					fmt.Fprintf(os.Stderr, "%s: config not found \"%s\", run -l for list\n", Danger("Error"), args[0])
+					os.Exit(1)
+				}
 			}
 		}
 	}
@@ -358,9 +676,10 @@ func main() {
 
 	start := time.Now()
 
-	run(conf)
+	run(ctx, conf)
 	display(conf, true)
 
 	log.Printf("Duration: %s", time.Since(start))
+	os.Exit(runStatus(ctx))
 
 }
@@ -1,15 +1,15 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io/fs"
 	"os"
 	"os/exec"
-	"os/user"
 	"reflect"
-	"regexp"
 	"strings"
+	"time"
 
 	"github.com/acarl005/stripansi"
 )
@@ -30,29 +30,67 @@ type llang struct {
 // yaml Type gen by: https://zhwt.github.io/yaml-to-go/
 
 type Action struct {
-	Name     string `yaml:"name"`
-	Command  string `yaml:"command"`
-	Object   string `yaml:"object"`
-	Type     string `yaml:"type"`
-	Level    int    `yaml:"level"`
-	Count    int    `yaml:"count"`
-	Regex    string `yaml:"regex"`
-	Titles   llang  `yaml:"title"`
-	Ask      llang  `yaml:"ask"`
-	askreply string
-	Requires []string `yaml:"require"`
-	Pkgs     string   `yaml:"pkgs"`
-	Test     string   `yaml:"test"`
-	Output   string
-	Id       int
+	Name        string `yaml:"name"`
+	Command     string `yaml:"command"`
+	Object      string `yaml:"object"`
+	Type        string `yaml:"type"`
+	Level       int    `yaml:"level"`
+	Count       int    `yaml:"count"`
+	Regex       string `yaml:"regex"`
+	Titles      llang  `yaml:"title"`
+	Ask         llang  `yaml:"ask"`
+	askreply    string
+	Requires    []string     `yaml:"require"`
+	Pkgs        string       `yaml:"pkgs"`
+	Test        string       `yaml:"test"`
+	Timeout     int          `yaml:"timeout"` // seconds
+	Chroot      bool         `yaml:"chroot"`  // safe to run in --root system
+	Redact      []RedactRule `yaml:"redact"`
+	Attach      []string     `yaml:"attach"` // files for bundle, glob
+	MaxLines    int          `yaml:"max_lines"`
+	MaxBytes    int          `yaml:"max_bytes"`
+	Needs       []string     `yaml:"needs"`     // actions to run before
+	OkStatus    []int        `yaml:"ok_status"` // exit statuses not failed, as 1 of grep without match
+	rules       []*RedactRule
+	vars        map[string]string // output of needed actions
+	conf        string            // command of its config, needs are in the same config
+	untrusted   *TrustError       // as root, config not trusted: refused by run()
+	raw         string            // output before filter()
+	Output      string            `yaml:"-"`
+	Stderr      string            `yaml:"-"`
+	Skipped     string            `yaml:"-"` // reason from valid()
+	Id          int               `yaml:"-"`
+	Status      int               `yaml:"-"` // exit status, -1 if not run
+	Duration    time.Duration     `yaml:"-"`
+	Redacted    int               `yaml:"-"` // count of values replaced by filter()
+	Checks      []RequireResult   `yaml:"-"`
+	TimedOut    bool              `yaml:"-"`
+	Canceled    bool              `yaml:"-"` // interrupted by Ctrl-C
+	OnHost      bool              `yaml:"-"` // with -root, command not chroot-safe was run on the live system
+	Elided      int               `yaml:"-"` // lines removed by truncate()
+	Attachments []Attachment      `yaml:"-"`
+}
+
+type RequireResult struct {
+	Require string `json:"require"`
+	Ok      bool   `json:"ok"`
+	Error   string `json:"error,omitempty"`
 }
 
 type Service struct {
 	Caption  string
 	Version  string
 	Command  string
-	wantSudo int      `yaml:"sudo"`
-	Actions  []Action `yaml:"actions"`
+	wantSudo int          `yaml:"sudo"`
+	Timeout  int          `yaml:"timeout"`  // default for actions, seconds
+	Fragment bool         `yaml:"fragment"` // only actions for "use:", not listed
+	Budget   int          `yaml:"budget"`   // max bytes of all outputs
+	Redact   []RedactRule `yaml:"redact"`
+	Layer    string       `yaml:"-"` // config layer, set by Directory.ForEach
+	Shadow   string       `yaml:"-"`
+	Actions  []Action     `yaml:"actions"`
+
+	untrusted *TrustError // as root, first included file not trusted
 }
 
 func (s *Service) ForEach(function func(action *Action)) {
@@ -67,7 +105,7 @@ func (s *Service) UseSudo() bool {
 	}
 	ok := false
 	s.ForEach(func(action *Action) {
-		if strings.Contains(action.Command, "sudo") {
+		if strings.Contains(action.Command, "sudo") || (rootFlag != "" && action.Chroot) {
 			ok = true
 			return
 		}
@@ -94,6 +132,9 @@ func (a Action) String() string {
 		if len(a.Requires) > 0 {
 			req = fmt.Sprintf("\t%-12s\t%v\n", "Require:", a.Requires)
 		}
+		if needs := a.dependencies(); len(needs) > 0 {
+			req += fmt.Sprintf("\t%-12s\t%v\n", "Needs:", needs)
+		}
 
 		pkgs := ""
 		if a.Pkgs != "" {
@@ -137,41 +178,93 @@ func (l llang) GetText() string {
 }
 
 // dependences are ok for run this action ?
-func (a *Action) valid() error {
+func (a *Action) valid(ctx context.Context) error {
+	a.Checks = nil
 	for _, req := range a.Requires {
-		if strings.HasPrefix(req, "bash:") {
-			req = req[5:]
-			if a.askreply != "" {
-				req = strings.ReplaceAll(req, "%ASK%", a.askreply)
-			}
-			if exec.Command("bash", "-c", req).Run() != nil {
-				return fmt.Errorf("bash condition false \"%s\"", req)
-			}
-		} else if req[0] == '/' {
-			if _, err := os.Stat(req); errors.Is(err, fs.ErrNotExist) {
-				return fmt.Errorf("file not found \"%s\"", req)
-			}
-		} else {
-			req = strings.ToLower(req)
-			if a.askreply != "" {
-				req = strings.ReplaceAll(req, "%ASK%", a.askreply)
-			}
-			if exec.Command("bash", "-c", fmt.Sprintf("LANG=C pacman -Qi %s", req)).Run() != nil {
-				return fmt.Errorf("package not found \"%s\"", req)
-			}
+		err := a.validRequire(ctx, req)
+		check := RequireResult{Require: req, Ok: err == nil}
+		if err != nil {
+			check.Error = err.Error()
+		}
+		a.Checks = append(a.Checks, check)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// check one require entry
+func (a *Action) validRequire(ctx context.Context, req string) error {
+	if strings.HasPrefix(req, "bash:") {
+		req = req[5:]
+		if a.askreply != "" {
+			req = strings.ReplaceAll(req, "%ASK%", a.askreply)
+		}
+		req = a.expandShell(req)
+		if _, err := runCommand(ctx, a.shell(req)); err != nil {
+			return fmt.Errorf("bash condition false \"%s\"", req)
+		}
+	} else if req[0] == '/' {
+		req, err := a.expand(req)
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stat(rootPath(req)); errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("file not found \"%s\"", req)
+		}
+	} else {
+		if a.askreply != "" {
+			req = strings.ReplaceAll(req, "%ASK%", a.askreply)
+		}
+		req, err := a.expand(req)
+		if err != nil {
+			return err
+		}
+		pm, pkg := splitPackage(req)
+		if !pm.Installed(ctx, pkg) {
+			return fmt.Errorf("%s package not found \"%s\"", pm.Name(), pkg)
 		}
 	}
 	return nil
 }
 
-// run command
-func (a *Action) exec() bool {
+// run command, killed after timeout
+func (a *Action) exec(ctx context.Context, timeout time.Duration) bool {
 	a.Output = ""
+	a.Stderr = ""
+	a.Skipped = ""
+	a.Status = -1
+	a.Redacted = 0
+	a.TimedOut = false
+	a.Canceled = false
+	a.OnHost = false
+	a.Elided = 0
+	start := time.Now()
+	defer func() { a.Duration = time.Since(start) }()
 	defer a.filter()
 
+	// interrupted before start
+	if ctx.Err() != nil {
+		a.Canceled = true
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	defer func() {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			a.TimedOut = true
+			fmt.Fprintf(os.Stderr, "%s: \"%s\" timed out after %s\n", Hilite("Warning"), a.Name, timeout)
+		} else if errors.Is(ctx.Err(), context.Canceled) {
+			a.Canceled = true
+		}
+	}()
+
 	// exit if Required not ok
-	err := a.valid()
+	err := a.valid(ctx)
 	if err != nil {
+		a.Skipped = err.Error()
 		fmt.Fprintf(os.Stderr, "%s: %s\n", Hilite("Warning"), err)
 		return false
 	}
@@ -179,7 +272,7 @@ func (a *Action) exec() bool {
 	vari := ""
 	//get value to include in command
 	if a.Test != "" {
-		s, _ := exec.Command("bash", "-c", "LANG=C "+a.Test).Output()
+		s, _ := runCommand(ctx, a.shell("LANG=C "+a.expandShell(a.Test)))
 		vari = strings.TrimSpace(string(s))
 	}
 
@@ -188,17 +281,36 @@ func (a *Action) exec() bool {
 		vari = a.askreply
 	}
 
+	// files, not command
+	if len(a.Attach) > 0 {
+		return a.attachFiles(ctx)
+	}
+
 	// shell command
 	if a.Command != "" {
-		cmd := a.Command
+		a.OnHost = rootFlag != "" && !a.Chroot
+		cmd := a.expandShell(a.Command)
 		if vari != "" {
 			cmd = strings.ReplaceAll(cmd, "%ASK%", vari)
 		}
-		out, err := exec.Command("bash", "-c", "LANG=C "+cmd+"|cat").Output()
+		// exit status is the one of the command, not of cat: a grep without match fails, unless "ok_status: [1]"
+		out, stderr, err := runCommandErr(ctx, a.shell("export LANG=C; {\n"+cmd+"\n} | cat; exit ${PIPESTATUS[0]}"))
+		// keep output on failure, it is useful for diagnostic
+		a.Output = stripansi.Strip(string(out))
+		a.Stderr = stripansi.Strip(string(stderr))
+		if ctx.Err() != nil {
+			return false
+		}
 		if err != nil {
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				a.Status = exitErr.ExitCode()
+				return a.okStatus()
+			}
+			a.Stderr += err.Error()
 			return false
 		}
-		a.Output = stripansi.Strip(string(out))
+		a.Status = 0
 		return true
 	}
 
@@ -207,12 +319,19 @@ func (a *Action) exec() bool {
 		obj, err := Objectfactory(a.Object)
 		if err == nil {
 			obj.init(a)
-			out := obj.exec()
+			out, err := obj.exec(ctx)
+			if err != nil {
+				a.Status = 1
+				a.Stderr = err.Error() + "\n"
+				return false
+			}
+			a.Status = 0
 			if out != "" {
 				a.Output = stripansi.Strip(out) // remove colors screen and log
 				return true
 			}
 		} else {
+			a.Stderr = err.Error()
 			fmt.Fprint(os.Stderr, err.Error())
 			return false
 		}
@@ -220,40 +339,32 @@ func (a *Action) exec() bool {
 	return false
 }
 
-func (a *Action) filter() {
-	if a.Output == "" {
-		return
+// action state for summary: "succeeded", "failed" or "skipped"
+func (a *Action) State() string {
+	if a.Skipped != "" {
+		return "skipped"
+	}
+	if (a.Status != 0 && !a.okStatus()) || a.TimedOut || a.Canceled {
+		return "failed"
 	}
+	return "succeeded"
+}
 
-	ipv6_regex := `[0-9A-Fa-f]{1,4}:[0-9A-Fa-f]{1,4}:[0-9A-Fa-f]{1,4}:`
-	ipv4_regex := `\d{1,3}\.\d{1,3}\.\d{1,3}\.\d{1,3}`
-	mac_regex := `[a-fA-F0-9:]{17}|[a-fA-F0-9]{12}`
-
-	re := regexp.MustCompile(ipv4_regex)
-	//a.Output = re.ReplaceAllString(a.Output, "[**ipv4**]")
-	submatchall := re.FindAllString(a.Output, -1)
-	for _, element := range submatchall {
-		fmt.Println(element)
-		if strings.HasPrefix(element, "192.168") ||
-			strings.HasPrefix(element, "255") ||
-			strings.HasPrefix(element, "0.") ||
-			strings.HasPrefix(element, "10.") {
-			continue
+// exit status in "ok_status:"
+func (a *Action) okStatus() bool {
+	for _, status := range a.OkStatus {
+		if a.Status == status {
+			return true
 		}
-		a.Output = strings.ReplaceAll(a.Output, element, "[**ipv4**]")
 	}
+	return false
+}
 
-	re = regexp.MustCompile(mac_regex)
-	a.Output = re.ReplaceAllString(a.Output, "[**filter**]") // mac and ipv6
-
-	re = regexp.MustCompile(ipv6_regex)
-	// can exclude fc00... and fe80...
-	a.Output = re.ReplaceAllString(a.Output, "[**ipv6**]")
-
-	me, err := user.Current()
-	if err == nil {
-		a.Output = strings.ReplaceAll(a.Output, me.Username, "[**$USER**]")
-	}
+func (a *Action) filter() {
+	a.raw = a.Output
+	a.Output = a.redact(a.Output)
+	a.Stderr = a.redact(a.Stderr)
+	a.truncate(a.MaxLines, a.MaxBytes)
 }
 
 func getUserLang() string {
@@ -0,0 +1,195 @@
+package main
+
+/*
+	order between actions: an action waits for the ones in "needs:"
+	and can use their output, "%{name.output}" is also a need
+	in commands, the output is shell-quoted: not between quotes in yaml
+	an output of several lines is fine in commands, but refused in a path
+	or a package name of "require:", the action is skipped
+
+	- name: "gateway"
+	  command: "ip route show default | awk '{print $3; exit}'"
+	- name: "router"
+	  command: "smbclient -NL %{gateway.output}"
+*/
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var varRegex = regexp.MustCompile(`%\{([^{}]+)\.output\}`)
+
+// names of "needs:" and of variables, once
+func (a *Action) dependencies() []string {
+	names := []string{}
+	seen := make(map[string]bool)
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	for _, name := range a.Needs {
+		add(name)
+	}
+	texts := append([]string{a.Command, a.Test}, a.Requires...)
+	texts = append(texts, a.Attach...)
+	for _, text := range texts {
+		for _, match := range varRegex.FindAllStringSubmatch(text, -1) {
+			add(match[1])
+		}
+	}
+	return names
+}
+
+// unknown actions and cycles, at load time
+func (s *Service) checkNeeds() error {
+	if errs := s.needErrors(); len(errs) > 0 {
+		return errs[0].Err
+	}
+	return nil
+}
+
+type NeedError struct {
+	Action int // index in s.Actions
+	Err    error
+}
+
+// all unknown actions and cycles, for -lint
+func (s *Service) needErrors() []NeedError {
+	errs := []NeedError{}
+	index := make(map[string]int)
+	for i := range s.Actions {
+		index[s.Actions[i].Name] = i
+	}
+	deps := make([][]int, len(s.Actions))
+	for i := range s.Actions {
+		for _, name := range s.Actions[i].dependencies() {
+			j, ok := index[name]
+			if !ok {
+				errs = append(errs, NeedError{i, fmt.Errorf("action \"%s\" needs unknown action \"%s\"", s.Actions[i].Name, name)})
+				continue
+			}
+			deps[i] = append(deps[i], j)
+		}
+	}
+
+	// depth first, 1: in path, 2: done
+	state := make([]int, len(s.Actions))
+	path := []int{}
+	var visit func(i int)
+	visit = func(i int) {
+		if state[i] == 2 {
+			return
+		}
+		if state[i] == 1 {
+			names := []string{}
+			for k := len(path) - 1; k >= 0; k-- {
+				names = append([]string{s.Actions[path[k]].Name}, names...)
+				if path[k] == i {
+					break
+				}
+			}
+			errs = append(errs, NeedError{i, fmt.Errorf("cycle in needs: %s -> %s", strings.Join(names, " -> "), s.Actions[i].Name)})
+			return
+		}
+		state[i] = 1
+		path = append(path, i)
+		for _, j := range deps[i] {
+			visit(j)
+		}
+		path = path[:len(path)-1]
+		state[i] = 2
+	}
+	for i := range s.Actions {
+		visit(i)
+	}
+	return errs
+}
+
+// "config:name", -r and -f merge actions of several configs
+func (a *Action) key() string {
+	return a.conf + ":" + a.Name
+}
+
+// indexes of needed actions, and names of the ones not in this run
+func (s *Service) needIndexes() ([][]int, [][]string) {
+	index := make(map[string]int)
+	for i := range s.Actions {
+		index[s.Actions[i].key()] = i
+	}
+	deps := make([][]int, len(s.Actions))
+	missing := make([][]string, len(s.Actions))
+	for i := range s.Actions {
+		for _, name := range s.Actions[i].dependencies() {
+			j, ok := index[s.Actions[i].conf+":"+name]
+			if !ok {
+				missing[i] = append(missing[i], name)
+			} else if j != i {
+				deps[i] = append(deps[i], j)
+			}
+		}
+	}
+	return deps, missing
+}
+
+// append actions needed and not selected (-r, -f), from all by key(); return their keys
+func (s *Service) addNeeded(all map[string]Action) []string {
+	in := make(map[string]bool)
+	for i := range s.Actions {
+		in[s.Actions[i].key()] = true
+	}
+	added := []string{}
+	for i := 0; i < len(s.Actions); i++ {
+		for _, name := range s.Actions[i].dependencies() {
+			key := s.Actions[i].conf + ":" + name
+			if n, ok := all[key]; ok && !in[key] {
+				in[key] = true
+				s.Actions = append(s.Actions, n)
+				added = append(added, key)
+			}
+		}
+	}
+	return added
+}
+
+// not run because a needed action did not succeed
+func (a *Action) skip(reason string) {
+	a.Output = ""
+	a.Stderr = ""
+	a.Status = -1
+	a.Skipped = reason
+}
+
+// output of needed actions, before redaction
+func (a *Action) setVars(needed []*Action) {
+	a.vars = make(map[string]string)
+	for _, n := range needed {
+		a.vars[n.Name] = strings.TrimSpace(n.raw)
+	}
+}
+
+// replace "%{name.output}" by the value, in a path or a package name: one line only
+func (a *Action) expand(text string) (string, error) {
+	for _, match := range varRegex.FindAllStringSubmatch(text, -1) {
+		if strings.ContainsAny(a.vars[match[1]], "\r\n") {
+			return text, fmt.Errorf("output of \"%s\" has several lines, not usable in \"%s\"", match[1], text)
+		}
+	}
+	return a.replaceVars(text, func(value string) string { return value }), nil
+}
+
+// replace "%{name.output}" by the quoted value, in a bash script
+func (a *Action) expandShell(text string) string {
+	return a.replaceVars(text, shellQuote)
+}
+
+func (a *Action) replaceVars(text string, quote func(string) string) string {
+	if a.vars == nil || !strings.Contains(text, "%{") {
+		return text
+	}
+	return varRegex.ReplaceAllStringFunc(text, func(s string) string {
+		return quote(a.vars[varRegex.FindStringSubmatch(s)[1]])
+	})
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExpandShell(t *testing.T) {
+	tests := []struct {
+		output  string
+		command string
+		want    string
+	}{
+		{"192.168.1.1\n", "ping %{gateway.output}", "ping '192.168.1.1'"},
+		{"x; touch /tmp/pwned", "echo %{gateway.output}", "echo 'x; touch /tmp/pwned'"},
+		{"it's $(id)", "echo %{gateway.output}", `echo 'it'\''s $(id)'`},
+		{"a\nb\n", "echo %{gateway.output}", "echo 'a\nb'"},
+	}
+	for _, tt := range tests {
+		a := &Action{Name: "router", Command: tt.command}
+		a.setVars([]*Action{{Name: "gateway", raw: tt.output}})
+		if got := a.expandShell(a.Command); got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.output, got, tt.want)
+		}
+	}
+}
+
+func TestExpand(t *testing.T) {
+	// path or package name: several lines refused
+	tests := []struct {
+		output  string
+		want    string
+		wantErr bool
+	}{
+		{"wlan0\n", "/sys/class/net/wlan0", false},
+		{"wlan0\nwlan1\n", "", true},
+	}
+	for _, tt := range tests {
+		a := &Action{Name: "wifi"}
+		a.setVars([]*Action{{Name: "iface", raw: tt.output}})
+		got, err := a.expand("/sys/class/net/%{iface.output}")
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: error %v", tt.output, err)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.output, got, tt.want)
+		}
+	}
+}
+
+func TestNeedsMergedConfigs(t *testing.T) {
+	// as -r wifi:router disk:router: needs are found in the same config
+	all := map[string]Action{}
+	for _, a := range []Action{
+		{Name: "gateway", Command: "ip route", conf: "wifi"},
+		{Name: "router", Command: "smbclient -NL %{gateway.output}", conf: "wifi"},
+		{Name: "gateway", Command: "other", conf: "disk"},
+		{Name: "router", Needs: []string{"gateway"}, conf: "disk"},
+		{Name: "lonely", Needs: []string{"gone"}, conf: "disk"},
+	} {
+		all[a.key()] = a
+	}
+	s := &Service{Actions: []Action{all["wifi:router"], all["disk:router"], all["disk:lonely"]}}
+	if got := strings.Join(s.addNeeded(all), " "); got != "wifi:gateway disk:gateway" {
+		t.Errorf("added %q", got)
+	}
+	deps, missing := s.needIndexes()
+	if len(deps[0]) != 1 || s.Actions[deps[0][0]].key() != "wifi:gateway" {
+		t.Errorf("wifi:router needs %v", deps[0])
+	}
+	if len(deps[1]) != 1 || s.Actions[deps[1][0]].key() != "disk:gateway" {
+		t.Errorf("disk:router needs %v", deps[1])
+	}
+	if len(missing[2]) != 1 || missing[2][0] != "gone" {
+		t.Errorf("missing %v", missing)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+/*
+	log files: $XDG_STATE_HOME/makelogs/<config>-<timestamp>.md, or -o path
+	"latest" link is the last log written, default file for -s
+	only the last LOGS_MAX logs of a config are kept in $XDG_STATE_HOME, with their .json, .html... files
+*/
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const LATEST = "latest"
+
+// logs kept by config, in state directory
+const LOGS_MAX = 20
+
+var outputFlag string = "" // file, or directory for timestamped names
+
+func logName(conf *Service) string {
+	if conf.Command == "" {
+		return "logs"
+	}
+	return conf.Command
+}
+
+// new log filename for this config
+func logFilename(conf *Service) string {
+	name := fmt.Sprintf("%s-%s.md", logName(conf), time.Now().Format("20060102-150405"))
+	if outputFlag == "" {
+		return filepath.Join(stateDir(), name)
+	}
+	if info, err := os.Stat(outputFlag); err == nil && info.IsDir() {
+		return filepath.Join(outputFlag, name)
+	}
+	return outputFlag
+}
+
+// point "latest" to this log, replaced atomically
+func linkLatest(logfile string) error {
+	abs, err := filepath.Abs(logfile)
+	if err != nil {
+		return err
+	}
+	link := filepath.Join(stateDir(), LATEST)
+	tmp := link + ".tmp"
+	os.Remove(tmp)
+	if err := os.Symlink(abs, tmp); err != nil {
+		return err
+	}
+	return os.Rename(tmp, link)
+}
+
+// last log written, empty if none
+func latestLog() string {
+	filename, err := filepath.EvalSymlinks(filepath.Join(stateDir(), LATEST))
+	if err != nil {
+		return ""
+	}
+	return filename
+}
+
+// remove the oldest logs of this config, and files written beside them
+func pruneLogs(conf *Service) {
+	dir := statePath()
+	name := logName(conf)
+	logRegex := regexp.MustCompile(`^` + regexp.QuoteMeta(name) + `-\d{8}-\d{6}\.md$`)
+	matches, _ := filepath.Glob(filepath.Join(dir, name+"-*.md"))
+	logs := []string{}
+	for _, match := range matches {
+		if logRegex.MatchString(filepath.Base(match)) {
+			logs = append(logs, match)
+		}
+	}
+	sort.Strings(logs)
+	for len(logs) > LOGS_MAX {
+		base := strings.TrimSuffix(logs[0], ".md")
+		companions, _ := filepath.Glob(base + ".*")
+		for _, filename := range companions {
+			os.Remove(filename)
+		}
+		logs = logs[1:]
+	}
+}
@@ -0,0 +1,249 @@
+package main
+
+/*
+	package managers used by "require:" and the PkgVer object
+*/
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+type PackageManager interface {
+	Name() string
+	// package is installed ?
+	Installed(ctx context.Context, pkg string) bool
+	// "name: version" lines for installed packages
+	Versions(ctx context.Context, pkgs []string) string
+}
+
+var pkgFlag string = "" // force package manager, else detected
+
+var (
+	packageManager     PackageManager
+	packageManagerOnce sync.Once
+)
+
+// package manager of this system, detected only once
+func getPackageManager() PackageManager {
+	packageManagerOnce.Do(func() {
+		name := pkgFlag
+		if name == "" {
+			name = detectPackageManager(rootPath("/etc/os-release"))
+		}
+		pm, err := PackageManagerFactory(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", Warning("Warning"), err)
+			pm = new(Pacman)
+		}
+		packageManager = pm
+	})
+	return packageManager
+}
+
+func PackageManagerFactory(name string) (PackageManager, error) {
+	switch name {
+	case "pacman":
+		return new(Pacman), nil
+	case "dpkg", "apt":
+		return new(Dpkg), nil
+	case "rpm", "dnf":
+		return new(Rpm), nil
+	case "flatpak":
+		return new(Flatpak), nil
+	}
+	return nil, fmt.Errorf("package manager \"%s\" not supported", name)
+}
+
+// package can be prefixed by manager as "flatpak:org.gimp.GIMP"
+func splitPackage(pkg string) (PackageManager, string) {
+	if i := strings.Index(pkg, ":"); i > 0 {
+		if pm, err := PackageManagerFactory(pkg[:i]); err == nil {
+			return pm, pkg[i+1:]
+		}
+	}
+	return getPackageManager(), pkg
+}
+
+// read ID and ID_LIKE from os-release
+func detectPackageManager(filename string) string {
+	file, err := os.Open(filename)
+	if err != nil {
+		return "pacman"
+	}
+	defer file.Close()
+
+	ids := []string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "ID=") || strings.HasPrefix(line, "ID_LIKE=") {
+			value := line[strings.Index(line, "=")+1:]
+			ids = append(ids, strings.Fields(strings.Trim(value, "\"'"))...)
+		}
+	}
+	for _, id := range ids {
+		switch id {
+		case "arch", "manjaro", "endeavouros", "artix":
+			return "pacman"
+		case "debian", "ubuntu":
+			return "dpkg"
+		case "fedora", "rhel", "centos", "suse", "opensuse":
+			return "rpm"
+		}
+	}
+	return "pacman"
+}
+
+// quote for bash
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func shellQuoteAll(pkgs []string, lower bool) string {
+	quoted := make([]string, len(pkgs))
+	for i, pkg := range pkgs {
+		if lower {
+			pkg = strings.ToLower(pkg)
+		}
+		quoted[i] = shellQuote(pkg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// ###############
+// Arch, Manjaro
+// ###############
+
+type Pacman struct{}
+
+func (p Pacman) Name() string {
+	return "pacman"
+}
+
+// local database is used if readable, else pacman command
+func (p Pacman) Installed(ctx context.Context, pkg string) bool {
+	if db, err := getPacmanDB(); err == nil {
+		_, ok := db.Get(pkg)
+		return ok
+	}
+	_, err := runCommand(ctx, "LANG=C pacman"+p.rootOption()+" -Qi "+shellQuote(strings.ToLower(pkg)))
+	return err == nil
+}
+
+func (p Pacman) rootOption() string {
+	if rootFlag == "" {
+		return ""
+	}
+	return " --root " + shellQuote(rootFlag) + " --dbpath " + shellQuote(rootPath("/var/lib/pacman"))
+}
+
+func (p Pacman) Versions(ctx context.Context, pkgs []string) string {
+	if db, err := getPacmanDB(); err == nil {
+		ret := ""
+		for _, name := range pkgs {
+			if pkg, ok := db.Get(name); ok {
+				ret += fmt.Sprintf("%s: %s\n", pkg.Name, pkg.Version)
+			}
+		}
+		return ret
+	}
+	cmd := fmt.Sprintf("LANG=C pacman%s -Qi %s |awk -F':' '/^Name/ {{n=$2}} /^Ver/ {{print n\": \"$2}}'", p.rootOption(), shellQuoteAll(pkgs, true))
+	out, _ := runCommand(ctx, cmd)
+	return string(out)
+}
+
+// ###############
+// Debian, Ubuntu
+// ###############
+
+type Dpkg struct{}
+
+func (d Dpkg) Name() string {
+	return "dpkg"
+}
+
+func (d Dpkg) rootOption() string {
+	if rootFlag == "" {
+		return ""
+	}
+	return " --admindir=" + shellQuote(rootPath("/var/lib/dpkg"))
+}
+
+func (d Dpkg) Installed(ctx context.Context, pkg string) bool {
+	out, err := runCommand(ctx, "LANG=C dpkg-query"+d.rootOption()+" -W -f='${db:Status-Abbrev}' "+shellQuote(strings.ToLower(pkg)))
+	return err == nil && strings.HasPrefix(string(out), "ii")
+}
+
+func (d Dpkg) Versions(ctx context.Context, pkgs []string) string {
+	cmd := fmt.Sprintf("LANG=C dpkg-query%s -W -f='${db:Status-Abbrev}${Package}: ${Version}\\n' %s | awk '/^ii/ {print $2\" \"$3}'", d.rootOption(), shellQuoteAll(pkgs, true))
+	out, _ := runCommand(ctx, cmd)
+	return string(out)
+}
+
+// ###############
+// Fedora, openSUSE
+// ###############
+
+type Rpm struct{}
+
+func (r Rpm) Name() string {
+	return "rpm"
+}
+
+func (r Rpm) rootOption() string {
+	if rootFlag == "" {
+		return ""
+	}
+	return " --root " + shellQuote(rootFlag)
+}
+
+func (r Rpm) Installed(ctx context.Context, pkg string) bool {
+	_, err := runCommand(ctx, "LANG=C rpm"+r.rootOption()+" -q "+shellQuote(strings.ToLower(pkg)))
+	return err == nil
+}
+
+func (r Rpm) Versions(ctx context.Context, pkgs []string) string {
+	cmd := fmt.Sprintf("LANG=C rpm%s -q --qf '%%{NAME}: %%{VERSION}-%%{RELEASE}\\n' %s | grep -v ' is not installed'", r.rootOption(), shellQuoteAll(pkgs, true))
+	out, _ := runCommand(ctx, cmd)
+	return string(out)
+}
+
+// ###############
+// Flatpak, application ids are case sensitive
+// ###############
+
+type Flatpak struct{}
+
+func (f Flatpak) Name() string {
+	return "flatpak"
+}
+
+func (f Flatpak) Installed(ctx context.Context, pkg string) bool {
+	_, err := runCommand(ctx, "LANG=C flatpak info "+shellQuote(pkg))
+	return err == nil
+}
+
+func (f Flatpak) Versions(ctx context.Context, pkgs []string) string {
+	out, err := runCommand(ctx, "LANG=C flatpak list --columns=application,version")
+	if err != nil {
+		return ""
+	}
+	ret := ""
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 1 {
+			continue
+		}
+		for _, pkg := range pkgs {
+			if fields[0] == pkg {
+				ret += fmt.Sprintf("%s: %s\n", pkg, strings.Join(fields[1:], " "))
+				break
+			}
+		}
+	}
+	return ret
+}
@@ -0,0 +1,161 @@
+package main
+
+/*
+	read pacman local database without pacman
+	/var/lib/pacman/local/<name>-<version>/desc
+*/
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const PACMAN_DB = "/var/lib/pacman/local"
+
+var pacmanDBFlag string = PACMAN_DB
+
+type LocalPackage struct {
+	Name        string
+	Version     string
+	InstallDate time.Time
+	Reason      int // 0: explicitly installed, 1: as dependency
+	Size        int64
+	Groups      []string
+	dir         string
+}
+
+type PacmanDB struct {
+	Dir      string
+	Packages map[string]*LocalPackage
+}
+
+var (
+	pacmanDB     *PacmanDB
+	pacmanDBErr  error
+	pacmanDBOnce sync.Once
+)
+
+// local database, loaded only once by run
+func getPacmanDB() (*PacmanDB, error) {
+	pacmanDBOnce.Do(func() {
+		dir := pacmanDBFlag
+		if dir == PACMAN_DB {
+			dir = rootPath(dir)
+		}
+		pacmanDB, pacmanDBErr = loadPacmanDB(dir)
+	})
+	return pacmanDB, pacmanDBErr
+}
+
+func loadPacmanDB(dir string) (*PacmanDB, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	db := &PacmanDB{Dir: dir, Packages: make(map[string]*LocalPackage)}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		pkg, err := readPackageDesc(filepath.Join(dir, entry.Name()))
+		if err != nil || pkg.Name == "" {
+			continue
+		}
+		db.Packages[pkg.Name] = pkg
+	}
+	return db, nil
+}
+
+// parse sections "%KEY%" followed by values until empty line
+func readSections(filename string, function func(key string, values []string)) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	key := ""
+	values := []string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if len(line) > 2 && line[0] == '%' && line[len(line)-1] == '%' {
+			key = line[1 : len(line)-1]
+			values = values[:0]
+			continue
+		}
+		if line == "" {
+			if key != "" {
+				function(key, values)
+			}
+			key = ""
+			continue
+		}
+		if key != "" {
+			values = append(values, line)
+		}
+	}
+	if key != "" {
+		function(key, values)
+	}
+	return scanner.Err()
+}
+
+func readPackageDesc(dir string) (*LocalPackage, error) {
+	pkg := &LocalPackage{dir: dir}
+	err := readSections(filepath.Join(dir, "desc"), func(key string, values []string) {
+		if len(values) < 1 {
+			return
+		}
+		switch key {
+		case "NAME":
+			pkg.Name = values[0]
+		case "VERSION":
+			pkg.Version = values[0]
+		case "INSTALLDATE":
+			if i, err := strconv.ParseInt(values[0], 10, 64); err == nil {
+				pkg.InstallDate = time.Unix(i, 0)
+			}
+		case "REASON":
+			pkg.Reason, _ = strconv.Atoi(values[0])
+		case "SIZE":
+			pkg.Size, _ = strconv.ParseInt(values[0], 10, 64)
+		case "GROUPS":
+			pkg.Groups = append([]string{}, values...)
+		}
+	})
+	return pkg, err
+}
+
+// files are read on demand, this file is big
+func (p *LocalPackage) Files() []string {
+	files := []string{}
+	readSections(filepath.Join(p.dir, "files"), func(key string, values []string) {
+		if key == "FILES" {
+			files = append(files, values...)
+		}
+	})
+	return files
+}
+
+func (db *PacmanDB) Get(name string) (*LocalPackage, bool) {
+	pkg, ok := db.Packages[strings.ToLower(name)]
+	return pkg, ok
+}
+
+// packages sorted by install date, newest first
+func (db *PacmanDB) Recent() []*LocalPackage {
+	pkgs := make([]*LocalPackage, 0, len(db.Packages))
+	for _, pkg := range db.Packages {
+		pkgs = append(pkgs, pkg)
+	}
+	sort.Slice(pkgs, func(i, j int) bool {
+		return pkgs[i].InstallDate.After(pkgs[j].InstallDate)
+	})
+	return pkgs
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testPacmanDB = "testdata/local"
+
+func TestReadPackageDesc(t *testing.T) {
+	tests := []struct {
+		dir  string
+		want LocalPackage
+	}{
+		{"pacman-6.0.2-6", LocalPackage{
+			Name:        "pacman",
+			Version:     "6.0.2-6",
+			InstallDate: time.Unix(1673900000, 0),
+			Reason:      1,
+			Size:        4780486,
+			Groups:      []string{"base-devel"},
+		}},
+		// empty groups, no reason: explicitly installed
+		{"linux-6.1.1.arch1-1", LocalPackage{
+			Name:        "linux",
+			Version:     "6.1.1.arch1-1",
+			InstallDate: time.Unix(1674000000, 0),
+			Size:        122683392,
+		}},
+		// no name, last section without empty line
+		{"broken-1-1", LocalPackage{
+			Version: "1-1",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dir, func(t *testing.T) {
+			pkg, err := readPackageDesc(testPacmanDB + "/" + tt.dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pkg.dir = ""
+			if !reflect.DeepEqual(*pkg, tt.want) {
+				t.Errorf("got %+v, want %+v", *pkg, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadPackageDescMissing(t *testing.T) {
+	if _, err := readPackageDesc(testPacmanDB + "/none-1-1"); err == nil {
+		t.Error("no error for missing desc")
+	}
+}
+
+func TestLocalPackageFiles(t *testing.T) {
+	tests := []struct {
+		dir  string
+		want []string
+	}{
+		// %BACKUP% is not in the list
+		{"pacman-6.0.2-6", []string{"etc/", "etc/makepkg.conf", "etc/pacman.conf", "usr/", "usr/bin/", "usr/bin/pacman"}},
+		{"linux-6.1.1.arch1-1", []string{"usr/", "usr/lib/", "usr/lib/modules/", "usr/lib/modules/6.1.1-arch1-1/vmlinuz"}},
+		// no files file
+		{"broken-1-1", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dir, func(t *testing.T) {
+			pkg := &LocalPackage{dir: testPacmanDB + "/" + tt.dir}
+			if got := pkg.Files(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadPacmanDB(t *testing.T) {
+	db, err := loadPacmanDB(testPacmanDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// package without name and ALPM_DB_VERSION are skipped
+	if len(db.Packages) != 2 {
+		t.Errorf("got %d packages, want 2", len(db.Packages))
+	}
+	if pkg, ok := db.Get("Pacman"); !ok || pkg.Version != "6.0.2-6" {
+		t.Errorf("Get(\"Pacman\") = %v, %v", pkg, ok)
+	}
+	if _, ok := db.Get("broken"); ok {
+		t.Error("Get(\"broken\") found")
+	}
+	recent := db.Recent()
+	if len(recent) != 2 || recent[0].Name != "linux" || recent[1].Name != "pacman" {
+		t.Errorf("Recent() not sorted newest first: %v", recent)
+	}
+}
+
+func TestPkgInfo(t *testing.T) {
+	db, err := loadPacmanDB(testPacmanDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := PkgInfo{pkgs: []string{"pacman", "unknown"}}.describe(db)
+	for _, want := range []string{"pacman 6.0.2-6\n", "(dependency)", "groups: base-devel\n", "files: 6\n", "config: /etc/pacman.conf\n"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("no %q in:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,387 @@
+package main
+
+/*
+	upload log to paste services, ordered backends with fallback
+
+	custom backends in <config dir>/paste/backends.yaml (admin then user):
+	order: ["mypaste", "0x0"]
+	backends:
+	  - name: "mypaste"
+	    type: "form"                    # multipart, form or post
+	    url: "https://paste.example.org/api"
+	    field: "content"
+	    plain: false                    # multipart: content as a text field, not a file
+	    fields:                         # extra fields
+	      expire: "1w"
+	    response: 'href="(https://[^"]+)"'   # regex, first group is the url
+	    timeout: 20
+	  - name: "mybin"
+	    type: "privatebin"              # encrypted in client, key only in url
+	    url: "https://bin.example.org/"
+	    expire: "1week"
+	    burn: false                     # delete after first read
+	encrypted_order: ["mybin", "privatebin"]   # with -encrypt
+*/
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const PASTE_TIMEOUT = 30 // seconds
+
+var (
+	pasteFlag string = "" // backends order, as "0x0,sprunge"
+	proxyFlag string = "" // else from HTTP_PROXY, HTTPS_PROXY
+)
+
+type PasteResult struct {
+	Backend     string
+	URL         string
+	DeleteToken string // if the backend returns one
+}
+
+type PasteBackend interface {
+	Name() string
+	Upload(ctx context.Context, content []byte, filename string) (PasteResult, error)
+}
+
+// backends where a paste can be removed with its token
+type PasteDeleter interface {
+	Delete(ctx context.Context, paste PasteResult) error
+}
+
+type PasteBackendConfig struct {
+	Name        string            `yaml:"name"`
+	Type        string            `yaml:"type"`
+	URL         string            `yaml:"url"`
+	Field       string            `yaml:"field"`
+	Plain       bool              `yaml:"plain"`
+	Fields      map[string]string `yaml:"fields"`
+	Response    string            `yaml:"response"`
+	TokenHeader string            `yaml:"token_header"`
+	Timeout     int               `yaml:"timeout"`
+	Expire      string            `yaml:"expire"`
+	Burn        bool              `yaml:"burn"`
+}
+
+type PasteConfig struct {
+	Order          []string             `yaml:"order"`
+	EncryptedOrder []string             `yaml:"encrypted_order"`
+	Backends       []PasteBackendConfig `yaml:"backends"`
+}
+
+var defaultPasteConfig = PasteConfig{
+	Order:          []string{"0x0", "sprunge"},
+	EncryptedOrder: []string{"privatebin"},
+	Backends: []PasteBackendConfig{
+		{Name: "0x0", Type: "multipart", URL: "https://0x0.st", Field: "file", TokenHeader: "X-Token"},
+		{Name: "sprunge", Type: "multipart", URL: "http://sprunge.us", Field: "sprunge", Plain: true},
+		{Name: "privatebin", Type: "privatebin", URL: "https://privatebin.net/", Expire: "1week"},
+	},
+}
+
+func PasteBackendFactory(conf PasteBackendConfig) (PasteBackend, error) {
+	if conf.URL == "" {
+		return nil, fmt.Errorf("paste backend \"%s\" without url", conf.Name)
+	}
+	var re *regexp.Regexp
+	if conf.Response != "" {
+		var err error
+		if re, err = regexp.Compile(conf.Response); err != nil {
+			return nil, fmt.Errorf("paste backend \"%s\": %w", conf.Name, err)
+		}
+	}
+	base := httpBackend{conf: conf, response: re}
+	switch conf.Type {
+	case "multipart", "":
+		if base.conf.Field == "" {
+			base.conf.Field = "file"
+		}
+		return &MultipartBackend{base}, nil
+	case "form":
+		if base.conf.Field == "" {
+			base.conf.Field = "content"
+		}
+		return &FormBackend{base}, nil
+	case "post":
+		return &PostBackend{base}, nil
+	case "privatebin":
+		return &PrivateBinBackend{base}, nil
+	}
+	return nil, fmt.Errorf("paste backend type \"%s\" not supported", conf.Type)
+}
+
+// defaults, then paste/backends.yaml of each layer, a backend replaces one of same name
+func (d Directory) pasteConfig() (PasteConfig, error) {
+	conf := defaultPasteConfig
+	conf.Backends = append([]PasteBackendConfig{}, defaultPasteConfig.Backends...)
+	for _, layer := range d.Layers {
+		if layer.Dir == "" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(layer.Dir, "paste", "backends.yaml"))
+		if err != nil {
+			continue
+		}
+		custom := PasteConfig{}
+		if err := yaml.Unmarshal(data, &custom); err != nil {
+			return conf, err
+		}
+		if len(custom.Order) > 0 {
+			conf.Order = custom.Order
+		}
+		if len(custom.EncryptedOrder) > 0 {
+			conf.EncryptedOrder = custom.EncryptedOrder
+		}
+	next:
+		for _, backend := range custom.Backends {
+			for i := range conf.Backends {
+				if conf.Backends[i].Name == backend.Name {
+					conf.Backends[i] = backend
+					continue next
+				}
+			}
+			conf.Backends = append(conf.Backends, backend)
+		}
+	}
+	return conf, nil
+}
+
+func (conf PasteConfig) backend(name string) (PasteBackendConfig, error) {
+	for _, backend := range conf.Backends {
+		if backend.Name == strings.TrimSpace(name) {
+			return backend, nil
+		}
+	}
+	return PasteBackendConfig{}, fmt.Errorf("paste backend \"%s\" not found", name)
+}
+
+// backend by name, in any order
+func (d Directory) PasteBackend(name string) (PasteBackend, error) {
+	conf, err := d.pasteConfig()
+	if err != nil {
+		return nil, err
+	}
+	backend, err := conf.backend(name)
+	if err != nil {
+		return nil, err
+	}
+	return PasteBackendFactory(backend)
+}
+
+// backends to try, encrypted: backends of "encrypted_order" only
+func (d Directory) PasteBackends(encrypted bool) ([]PasteBackend, error) {
+	conf, err := d.pasteConfig()
+	if err != nil {
+		return nil, err
+	}
+	order := conf.Order
+	if encrypted {
+		order = conf.EncryptedOrder
+	}
+	if pasteFlag != "" {
+		order = strings.Split(pasteFlag, ",")
+	}
+
+	backends := []PasteBackend{}
+	for _, name := range order {
+		backend, err := conf.backend(name)
+		if err != nil {
+			return nil, err
+		}
+		if encrypted && backend.Type != "privatebin" {
+			return nil, fmt.Errorf("paste backend \"%s\" can not encrypt", backend.Name)
+		}
+		b, err := PasteBackendFactory(backend)
+		if err != nil {
+			return nil, err
+		}
+		backends = append(backends, b)
+	}
+	return backends, nil
+}
+
+// try backends in order, first success is returned
+func uploadContent(ctx context.Context, backends []PasteBackend, content []byte, filename string) (PasteResult, error) {
+	errs := []string{}
+	for _, backend := range backends {
+		result, err := backend.Upload(ctx, content, filename)
+		if err == nil {
+			return result, nil
+		}
+		fmt.Fprintf(os.Stderr, "%s: %s: %s\n", Warning("Warning"), backend.Name(), err)
+		errs = append(errs, backend.Name())
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return PasteResult{}, fmt.Errorf("upload failed with: %s", strings.Join(errs, ", "))
+}
+
+// ###############
+// common http part
+// ###############
+
+type httpBackend struct {
+	conf     PasteBackendConfig
+	response *regexp.Regexp
+}
+
+func (h httpBackend) Name() string {
+	return h.conf.Name
+}
+
+func httpClient(timeout int) (*http.Client, error) {
+	if timeout == 0 {
+		timeout = PASTE_TIMEOUT
+	}
+	proxy := http.ProxyFromEnvironment
+	if proxyFlag != "" {
+		u, err := url.Parse(proxyFlag)
+		if err != nil {
+			return nil, err
+		}
+		proxy = http.ProxyURL(u)
+	}
+	return &http.Client{
+		Timeout:   time.Duration(timeout) * time.Second,
+		Transport: &http.Transport{Proxy: proxy},
+	}, nil
+}
+
+// post body, return response body if status is 2xx
+func (h httpBackend) post(ctx context.Context, body io.Reader, header http.Header) ([]byte, http.Header, error) {
+	client, err := httpClient(h.conf.Timeout)
+	if err != nil {
+		return nil, nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.conf.URL, body)
+	if err != nil {
+		return nil, nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("User-Agent", "makelogs/"+Version)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, nil, fmt.Errorf("http %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+	return data, resp.Header, nil
+}
+
+func (h httpBackend) send(ctx context.Context, body io.Reader, contentType string) (PasteResult, error) {
+	data, header, err := h.post(ctx, body, http.Header{"Content-Type": {contentType}})
+	if err != nil {
+		return PasteResult{}, err
+	}
+
+	result := PasteResult{Backend: h.conf.Name, URL: strings.TrimSpace(string(data))}
+	if h.response != nil {
+		match := h.response.FindStringSubmatch(string(data))
+		if match == nil {
+			return PasteResult{}, errors.New("url not found in response")
+		}
+		result.URL = match[len(match)-1]
+	}
+	if h.conf.TokenHeader != "" {
+		result.DeleteToken = header.Get(h.conf.TokenHeader)
+	}
+	if !strings.HasPrefix(result.URL, "http") {
+		return PasteResult{}, fmt.Errorf("bad response \"%s\"", result.URL)
+	}
+	return result, nil
+}
+
+// ###############
+// 0x0.st: multipart form with file, sprunge: with text field
+// ###############
+
+type MultipartBackend struct {
+	httpBackend
+}
+
+func (m *MultipartBackend) Upload(ctx context.Context, content []byte, filename string) (PasteResult, error) {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for k, v := range m.conf.Fields {
+		w.WriteField(k, v)
+	}
+	if m.conf.Plain {
+		w.WriteField(m.conf.Field, string(content))
+	} else {
+		part, err := w.CreateFormFile(m.conf.Field, filepath.Base(filename))
+		if err != nil {
+			return PasteResult{}, err
+		}
+		part.Write(content)
+	}
+	w.Close()
+	return m.send(ctx, &body, w.FormDataContentType())
+}
+
+// 0x0 style: post "token" and "delete" to the paste url
+func (m *MultipartBackend) Delete(ctx context.Context, paste PasteResult) error {
+	if paste.DeleteToken == "" {
+		return errors.New("no delete token for this paste")
+	}
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	w.WriteField("token", paste.DeleteToken)
+	w.WriteField("delete", "")
+	w.Close()
+	target := m.httpBackend
+	target.conf.URL = paste.URL
+	_, _, err := target.post(ctx, &body, http.Header{"Content-Type": {w.FormDataContentType()}})
+	return err
+}
+
+// ###############
+// pastebin style: urlencoded form
+// ###############
+
+type FormBackend struct {
+	httpBackend
+}
+
+func (f *FormBackend) Upload(ctx context.Context, content []byte, filename string) (PasteResult, error) {
+	values := url.Values{}
+	for k, v := range f.conf.Fields {
+		values.Set(k, v)
+	}
+	values.Set(f.conf.Field, string(content))
+	return f.send(ctx, strings.NewReader(values.Encode()), "application/x-www-form-urlencoded")
+}
+
+// ###############
+// raw body
+// ###############
+
+type PostBackend struct {
+	httpBackend
+}
+
+func (p *PostBackend) Upload(ctx context.Context, content []byte, filename string) (PasteResult, error) {
+	return p.send(ctx, bytes.NewReader(content), "text/plain; charset=utf-8")
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testPaste = "### log\n:: action\n```\nok\n```\n"
+
+func testBackend(t *testing.T, conf PasteBackendConfig) PasteBackend {
+	t.Helper()
+	backend, err := PasteBackendFactory(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return backend
+}
+
+func TestMultipartBackend(t *testing.T) {
+	tests := []struct {
+		name  string
+		plain bool
+	}{
+		{"file", false}, // 0x0: -F 'file=@log.md'
+		{"plain", true}, // sprunge: -F 'sprunge=<-'
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if err := r.ParseMultipartForm(1 << 20); err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				if r.FormValue("expires") != "24" {
+					http.Error(w, "no extra field", http.StatusBadRequest)
+					return
+				}
+				content := r.FormValue("paste")
+				if file, header, err := r.FormFile("paste"); err == nil {
+					defer file.Close()
+					data, _ := io.ReadAll(file)
+					content = string(data)
+					w.Header().Set("X-Token", "token-"+header.Filename)
+				}
+				if content != testPaste {
+					http.Error(w, "bad content", http.StatusBadRequest)
+					return
+				}
+				fmt.Fprintln(w, "https://paste.example.org/abc")
+			}))
+			defer server.Close()
+
+			backend := testBackend(t, PasteBackendConfig{
+				Name: tt.name, Type: "multipart", URL: server.URL, Field: "paste", Plain: tt.plain,
+				Fields: map[string]string{"expires": "24"}, TokenHeader: "X-Token",
+			})
+			result, err := backend.Upload(context.Background(), []byte(testPaste), "/tmp/log.md")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.URL != "https://paste.example.org/abc" || result.Backend != tt.name {
+				t.Errorf("got %+v", result)
+			}
+			if token := map[bool]string{false: "token-log.md", true: ""}[tt.plain]; result.DeleteToken != token {
+				t.Errorf("delete token %q, want %q", result.DeleteToken, token)
+			}
+		})
+	}
+}
+
+func TestMultipartBackendDelete(t *testing.T) {
+	deleted := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseMultipartForm(1 << 20)
+		if _, ok := r.MultipartForm.Value["delete"]; !ok || r.FormValue("token") != "secret" {
+			http.Error(w, "refused", http.StatusForbidden)
+			return
+		}
+		deleted = r.URL.Path
+	}))
+	defer server.Close()
+
+	backend := testBackend(t, PasteBackendConfig{Name: "0x0", Type: "multipart", URL: "http://unused"})
+	deleter := backend.(PasteDeleter)
+	if err := deleter.Delete(context.Background(), PasteResult{URL: server.URL + "/abc.md", DeleteToken: "secret"}); err != nil {
+		t.Fatal(err)
+	}
+	if deleted != "/abc.md" {
+		t.Errorf("deleted %q", deleted)
+	}
+	if err := deleter.Delete(context.Background(), PasteResult{URL: server.URL + "/abc.md", DeleteToken: "bad"}); err == nil {
+		t.Error("no error for refused delete")
+	}
+}
+
+func TestFormBackend(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/x-www-form-urlencoded" || r.FormValue("content") != testPaste || r.FormValue("lang") != "md" {
+			http.Error(w, "bad form", http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, `<a href="https://paste.example.org/p/xyz">paste</a>`)
+	}))
+	defer server.Close()
+
+	backend := testBackend(t, PasteBackendConfig{
+		Name: "form", Type: "form", URL: server.URL,
+		Fields: map[string]string{"lang": "md"}, Response: `href="(https://[^"]+)"`,
+	})
+	result, err := backend.Upload(context.Background(), []byte(testPaste), "log.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.URL != "https://paste.example.org/p/xyz" {
+		t.Errorf("got %q", result.URL)
+	}
+}
+
+func TestPostBackend(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		response string
+		wantErr  bool
+	}{
+		{"ok", http.StatusOK, "https://paste.example.org/raw\n", false},
+		{"server error", http.StatusInternalServerError, "down", true},
+		{"not an url", http.StatusOK, "quota exceeded", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				data, _ := io.ReadAll(r.Body)
+				if string(data) != testPaste || !strings.HasPrefix(r.Header.Get("Content-Type"), "text/plain") {
+					http.Error(w, "bad body", http.StatusBadRequest)
+					return
+				}
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.response)
+			}))
+			defer server.Close()
+
+			backend := testBackend(t, PasteBackendConfig{Name: "post", Type: "post", URL: server.URL})
+			result, err := backend.Upload(context.Background(), []byte(testPaste), "log.md")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && result.URL != "https://paste.example.org/raw" {
+				t.Errorf("got %q", result.URL)
+			}
+		})
+	}
+}
+
+func base58Decode(s string) []byte {
+	n := new(big.Int)
+	for _, c := range s {
+		n.Mul(n, big.NewInt(58))
+		n.Add(n, big.NewInt(int64(strings.IndexRune(base58Alphabet, c))))
+	}
+	out := n.Bytes()
+	for i := 0; i < len(s) && s[i] == '1'; i++ {
+		out = append([]byte{0}, out...)
+	}
+	return out
+}
+
+func TestPrivateBinBackend(t *testing.T) {
+	var sent struct {
+		V     int             `json:"v"`
+		Adata json.RawMessage `json:"adata"`
+		Ct    string          `json:"ct"`
+		Meta  struct {
+			Expire string `json:"expire"`
+		} `json:"meta"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request map[string]interface{}
+		data, _ := io.ReadAll(r.Body)
+		json.Unmarshal(data, &request)
+		if request["pasteid"] != nil {
+			if request["pasteid"] == "abc" && request["deletetoken"] == "tok" {
+				fmt.Fprint(w, `{"status":0}`)
+			} else {
+				fmt.Fprint(w, `{"status":1,"message":"wrong token"}`)
+			}
+			return
+		}
+		json.Unmarshal(data, &sent)
+		fmt.Fprint(w, `{"status":0,"id":"abc","deletetoken":"tok"}`)
+	}))
+	defer server.Close()
+
+	backend := testBackend(t, PasteBackendConfig{Name: "privatebin", Type: "privatebin", URL: server.URL + "/", Expire: "1day"})
+	result, err := backend.Upload(context.Background(), []byte(testPaste), "log.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(result.URL, server.URL+"/?abc#") || result.DeleteToken != "tok" {
+		t.Fatalf("got %+v", result)
+	}
+	if sent.V != 2 || sent.Meta.Expire != "1day" {
+		t.Errorf("sent %+v", sent)
+	}
+
+	// decrypt with the key of the url, as the browser
+	var adata []interface{}
+	json.Unmarshal(sent.Adata, &adata)
+	spec := adata[0].([]interface{})
+	iv, _ := base64.StdEncoding.DecodeString(spec[0].(string))
+	salt, _ := base64.StdEncoding.DecodeString(spec[1].(string))
+	key := base58Decode(result.URL[strings.Index(result.URL, "#")+1:])
+	block, _ := aes.NewCipher(pbkdf2SHA256(key, salt, int(spec[2].(float64)), 32))
+	gcm, _ := cipher.NewGCMWithNonceSize(block, len(iv))
+	ct, _ := base64.StdEncoding.DecodeString(sent.Ct)
+	plain, err := gcm.Open(nil, iv, ct, sent.Adata)
+	if err != nil {
+		t.Fatal(err)
+	}
+	paste := map[string]string{}
+	json.Unmarshal(plain, &paste)
+	if paste["paste"] != testPaste {
+		t.Errorf("decrypted %q", paste["paste"])
+	}
+
+	deleter := backend.(PasteDeleter)
+	if err := deleter.Delete(context.Background(), result); err != nil {
+		t.Error(err)
+	}
+	result.DeleteToken = "bad"
+	if err := deleter.Delete(context.Background(), result); err == nil {
+		t.Error("no error for refused delete")
+	}
+}
+
+func TestUploadContentFallback(t *testing.T) {
+	calls := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.URL.Path)
+		if r.URL.Path == "/down" {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintf(w, "https://paste.example.org%s\n", r.URL.Path)
+	}))
+	defer server.Close()
+
+	backend := func(name string) PasteBackend {
+		return testBackend(t, PasteBackendConfig{Name: name, Type: "post", URL: server.URL + "/" + name})
+	}
+	tests := []struct {
+		name      string
+		backends  []PasteBackend
+		wantCalls string
+		wantURL   string
+	}{
+		{"first ok", []PasteBackend{backend("one"), backend("two")}, "/one", "https://paste.example.org/one"},
+		{"fallback", []PasteBackend{backend("down"), backend("two"), backend("three")}, "/down /two", "https://paste.example.org/two"},
+		{"all fail", []PasteBackend{backend("down"), backend("down")}, "/down /down", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls = nil
+			result, err := uploadContent(context.Background(), tt.backends, []byte(testPaste), "log.md")
+			if got := strings.Join(calls, " "); got != tt.wantCalls {
+				t.Errorf("calls %q, want %q", got, tt.wantCalls)
+			}
+			if tt.wantURL == "" && err == nil {
+				t.Error("no error when all backends fail")
+			}
+			if result.URL != tt.wantURL {
+				t.Errorf("url %q, want %q", result.URL, tt.wantURL)
+			}
+		})
+	}
+}
+
+func TestPasteBackendsOrder(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "paste"), 0755)
+	os.WriteFile(filepath.Join(dir, "paste", "backends.yaml"), []byte(`
+order: ["mine", "0x0"]
+backends:
+  - name: "mine"
+    type: "post"
+    url: "https://paste.example.org/"
+`), 0644)
+	d := Directory{Layers: []Layer{{Name: "embedded"}, {Name: "user", Dir: dir}}}
+
+	tests := []struct {
+		paste     string
+		encrypted bool
+		want      string
+		wantErr   bool
+	}{
+		{"", false, "mine 0x0", false},
+		{"", true, "privatebin", false},
+		{"sprunge,mine", false, "sprunge mine", false},
+		{"mine", true, "", true}, // can not encrypt
+		{"unknown", false, "", true},
+	}
+	defer func() { pasteFlag = "" }()
+	for _, tt := range tests {
+		pasteFlag = tt.paste
+		backends, err := d.PasteBackends(tt.encrypted)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("-paste %q: error %v", tt.paste, err)
+			continue
+		}
+		names := []string{}
+		for _, backend := range backends {
+			names = append(names, backend.Name())
+		}
+		if got := strings.Join(names, " "); got != tt.want {
+			t.Errorf("-paste %q: got %q, want %q", tt.paste, got, tt.want)
+		}
+	}
+}
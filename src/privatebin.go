@@ -0,0 +1,213 @@
+package main
+
+/*
+	PrivateBin api v2: paste is encrypted before upload, key is only in url fragment
+
+	key: 32 random bytes, base58 in url "#..."
+	aes-256-gcm, key derived by pbkdf2-sha256 (100000 iterations, 8 bytes salt)
+	adata (format, burn...) is authenticated with the ciphertext
+*/
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+)
+
+const (
+	PRIVATEBIN_ITERATIONS = 100000
+	PRIVATEBIN_EXPIRE     = "1week"
+)
+
+var (
+	encryptFlag bool   = false // upload with encrypted backends only
+	expireFlag  string = ""    // 5min, 10min, 1hour, 1day, 1week, 1month, 1year, never
+	burnFlag    bool   = false // paste deleted after first read
+)
+
+type PrivateBinBackend struct {
+	httpBackend
+}
+
+type privateBinResponse struct {
+	Status      int    `json:"status"`
+	Message     string `json:"message"`
+	ID          string `json:"id"`
+	DeleteToken string `json:"deletetoken"`
+}
+
+func (p *PrivateBinBackend) Upload(ctx context.Context, content []byte, filename string) (PasteResult, error) {
+	expire := p.conf.Expire
+	if expireFlag != "" {
+		expire = expireFlag
+	}
+	if expire == "" {
+		expire = PRIVATEBIN_EXPIRE
+	}
+	burn := 0
+	if p.conf.Burn || burnFlag {
+		burn = 1
+	}
+
+	key := make([]byte, 32)
+	salt := make([]byte, 8)
+	iv := make([]byte, 16)
+	for _, b := range [][]byte{key, salt, iv} {
+		if _, err := rand.Read(b); err != nil {
+			return PasteResult{}, err
+		}
+	}
+
+	// [[iv, salt, iterations, keysize, tagsize, algo, mode, compression], format, discussion, burn]
+	adata := []interface{}{
+		[]interface{}{
+			base64.StdEncoding.EncodeToString(iv),
+			base64.StdEncoding.EncodeToString(salt),
+			PRIVATEBIN_ITERATIONS, 256, 128, "aes", "gcm", "none",
+		},
+		"markdown", 0, burn,
+	}
+	aad, err := json.Marshal(adata)
+	if err != nil {
+		return PasteResult{}, err
+	}
+	plain, err := json.Marshal(map[string]string{"paste": string(content)})
+	if err != nil {
+		return PasteResult{}, err
+	}
+
+	block, err := aes.NewCipher(pbkdf2SHA256(key, salt, PRIVATEBIN_ITERATIONS, 32))
+	if err != nil {
+		return PasteResult{}, err
+	}
+	gcm, err := cipher.NewGCMWithNonceSize(block, len(iv))
+	if err != nil {
+		return PasteResult{}, err
+	}
+	ct := gcm.Seal(nil, iv, plain, aad)
+
+	body, err := json.Marshal(map[string]interface{}{
+		"v":     2,
+		"adata": json.RawMessage(aad),
+		"ct":    base64.StdEncoding.EncodeToString(ct),
+		"meta":  map[string]string{"expire": expire},
+	})
+	if err != nil {
+		return PasteResult{}, err
+	}
+
+	data, _, err := p.post(ctx, bytes.NewReader(body), http.Header{
+		"Content-Type":     {"application/json"},
+		"X-Requested-With": {"JSONHttpRequest"},
+	})
+	if err != nil {
+		return PasteResult{}, err
+	}
+	response := privateBinResponse{}
+	if err := json.Unmarshal(data, &response); err != nil {
+		return PasteResult{}, fmt.Errorf("bad response: %w", err)
+	}
+	if response.Status != 0 || response.ID == "" {
+		return PasteResult{}, fmt.Errorf("paste refused: %s", response.Message)
+	}
+
+	base, err := url.Parse(p.conf.URL)
+	if err != nil {
+		return PasteResult{}, err
+	}
+	base.RawQuery = response.ID
+	base.Fragment = ""
+	return PasteResult{
+		Backend:     p.conf.Name,
+		URL:         base.String() + "#" + base58Encode(key),
+		DeleteToken: response.DeleteToken,
+	}, nil
+}
+
+func (p *PrivateBinBackend) Delete(ctx context.Context, paste PasteResult) error {
+	if paste.DeleteToken == "" {
+		return errors.New("no delete token for this paste")
+	}
+	u, err := url.Parse(paste.URL)
+	if err != nil {
+		return err
+	}
+	body, _ := json.Marshal(map[string]string{"pasteid": u.RawQuery, "deletetoken": paste.DeleteToken})
+	data, _, err := p.post(ctx, bytes.NewReader(body), http.Header{
+		"Content-Type":     {"application/json"},
+		"X-Requested-With": {"JSONHttpRequest"},
+	})
+	if err != nil {
+		return err
+	}
+	response := privateBinResponse{}
+	if err := json.Unmarshal(data, &response); err != nil {
+		return fmt.Errorf("bad response: %w", err)
+	}
+	if response.Status != 0 {
+		return fmt.Errorf("delete refused: %s", response.Message)
+	}
+	return nil
+}
+
+// rfc 8018, only the sha256 variant used by PrivateBin
+func pbkdf2SHA256(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	hashLen := prf.Size()
+	blocks := (keyLen + hashLen - 1) / hashLen
+	dk := make([]byte, 0, blocks*hashLen)
+	buf := make([]byte, 4)
+	for block := 1; block <= blocks; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(buf, uint32(block))
+		prf.Write(buf)
+		u := prf.Sum(nil)
+		t := append([]byte{}, u...)
+		for n := 1; n < iterations; n++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for i := range t {
+				t[i] ^= u[i]
+			}
+		}
+		dk = append(dk, t...)
+	}
+	return dk[:keyLen]
+}
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// bitcoin alphabet, leading zero bytes are "1"
+func base58Encode(data []byte) string {
+	n := new(big.Int).SetBytes(data)
+	radix := big.NewInt(58)
+	mod := new(big.Int)
+	out := []byte{}
+	for n.Sign() > 0 {
+		n.DivMod(n, radix, mod)
+		out = append(out, base58Alphabet[mod.Int64()])
+	}
+	for _, b := range data {
+		if b != 0 {
+			break
+		}
+		out = append(out, base58Alphabet[0])
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return string(out)
+}
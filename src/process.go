@@ -0,0 +1,69 @@
+package main
+
+/*
+	run shell commands with a deadline
+*/
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+const DEFAULT_TIMEOUT = 120 // seconds
+
+var timeoutFlag int = DEFAULT_TIMEOUT
+
+// run a bash script, return only stdout
+func runCommand(ctx context.Context, script string) ([]byte, error) {
+	out, _, err := runCommandErr(ctx, script)
+	return out, err
+}
+
+// run a bash script in its own process group, all the group is killed when ctx is done
+// stdout and stderr captured before the kill are returned with ctx.Err()
+func runCommandErr(ctx context.Context, script string) ([]byte, []byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("bash", "-c", script)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if rootFlag != "" {
+		// yaml commands not run in chroot can use $SYSROOT
+		cmd.Env = append(os.Environ(), "SYSROOT="+rootFlag)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, nil, err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			// negative pid: kill children (pipes, subshells) and not only bash
+			syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		case <-done:
+		}
+	}()
+	err := cmd.Wait()
+	close(done)
+
+	if ctx.Err() != nil {
+		return stdout.Bytes(), stderr.Bytes(), ctx.Err()
+	}
+	return stdout.Bytes(), stderr.Bytes(), err
+}
+
+// action timeout, else service timeout, else global default
+func (s *Service) timeout(a *Action) time.Duration {
+	t := a.Timeout
+	if t == 0 {
+		t = s.Timeout
+	}
+	if t == 0 {
+		t = timeoutFlag
+	}
+	return time.Duration(t) * time.Second
+}
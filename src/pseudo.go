@@ -0,0 +1,113 @@
+package main
+
+/*
+	pseudonymization: each distinct secret gets a stable token for all the run
+	"[ipv4-1]", "[mac-3]"; the mapping can be saved in a local file
+	to read replies of helpers: makelogs -unmap logs.map reply.txt
+*/
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	pseudoFlag    bool   = false
+	pseudoMapFlag string = "" // mapping file, only written if set
+)
+
+type Pseudonyms struct {
+	mu     sync.Mutex
+	tokens map[string]string // rule + "\x00" + value -> token
+	values map[string]string // token -> value
+	next   map[string]int
+}
+
+var pseudonyms = Pseudonyms{
+	tokens: make(map[string]string),
+	values: make(map[string]string),
+	next:   make(map[string]int),
+}
+
+// same value of same rule, same token
+func (p *Pseudonyms) token(rule string, value string) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	key := rule + "\x00" + value
+	if token, ok := p.tokens[key]; ok {
+		return token
+	}
+	p.next[rule]++
+	token := fmt.Sprintf("[%s-%d]", rule, p.next[rule])
+	p.tokens[key] = token
+	p.values[token] = value
+	return token
+}
+
+// write "token<tab>value" lines, readable only by user
+func (p *Pseudonyms) save(filename string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	f, err := os.OpenFile(filename, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	tokens := make([]string, 0, len(p.values))
+	for token := range p.values {
+		tokens = append(tokens, token)
+	}
+	sort.Strings(tokens)
+	for _, token := range tokens {
+		fmt.Fprintf(f, "%s\t%s\n", token, p.values[token])
+	}
+	return nil
+}
+
+func loadPseudoMap(filename string) (map[string]string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), "\t", 2)
+		if len(fields) == 2 {
+			values[fields[0]] = fields[1]
+		}
+	}
+	return values, scanner.Err()
+}
+
+// replace tokens by real values in text from file or stdin
+func unmapCommand(mapfile string, filename string) error {
+	values, err := loadPseudoMap(mapfile)
+	if err != nil {
+		return err
+	}
+	var in io.Reader = os.Stdin
+	if filename != "" {
+		file, err := os.Open(filename)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		in = file
+	}
+	data, err := io.ReadAll(in)
+	if err != nil {
+		return err
+	}
+	pairs := make([]string, 0, len(values)*2)
+	for token, value := range values {
+		pairs = append(pairs, token, value)
+	}
+	fmt.Print(strings.NewReplacer(pairs...).Replace(string(data)))
+	return nil
+}
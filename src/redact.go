@@ -0,0 +1,285 @@
+package main
+
+/*
+	redaction rules, built-in defaults then "redact:" of service and action
+	a rule with the name of a previous one replaces it, rules without name are added
+
+	redact:
+	  - name: "serial"
+	    regex: "SN[0-9]+"
+	    replace: "[**serial**]"     # default "[**<name>**]"
+	  - name: "ipv4"
+	    regex: '\d{1,3}\.\d{1,3}\.\d{1,3}\.\d{1,3}'
+	    allow:                      # cidr or prefix not replaced
+	      - "172.16.0.0/12"
+*/
+import (
+	"fmt"
+	"net"
+	"os/user"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type RedactRule struct {
+	Name     string   `yaml:"name"`
+	Regex    string   `yaml:"regex"`
+	Literal  string   `yaml:"literal"`
+	Replace  string   `yaml:"replace"`
+	Allow    []string `yaml:"allow"`
+	re       *regexp.Regexp
+	literals []string
+	nets     []*net.IPNet
+	prefix   []string
+	unnamed  bool // never replaces a previous rule
+}
+
+var defaultRules = []RedactRule{
+	{
+		Name:    "ipv4",
+		Regex:   `\d{1,3}\.\d{1,3}\.\d{1,3}\.\d{1,3}`,
+		Replace: "[**ipv4**]",
+		Allow:   []string{"192.168.0.0/16", "10.0.0.0/8", "0.0.0.0/8", "255.0.0.0/8"},
+	},
+	{
+		Name:    "mac",
+		Regex:   `[a-fA-F0-9:]{17}|[a-fA-F0-9]{12}`,
+		Replace: "[**filter**]",
+	},
+	{
+		// can exclude fc00... and fe80...
+		Name:    "ipv6",
+		Regex:   `[0-9A-Fa-f]{1,4}:[0-9A-Fa-f]{1,4}:[0-9A-Fa-f]{1,4}:`,
+		Replace: "[**ipv6**]",
+	},
+}
+
+var (
+	builtinRules      []*RedactRule
+	builtinRulesOnce  sync.Once
+	detectedRulesList []*RedactRule
+	detectedRulesOnce sync.Once
+)
+
+// count of replacements by rule name for this run
+var (
+	redactCounts   = make(map[string]int)
+	redactCountsMu sync.Mutex
+)
+
+func (r *RedactRule) compile() error {
+	label := "redact rule"
+	if r.Name != "" && !r.unnamed {
+		label = fmt.Sprintf("redact rule \"%s\"", r.Name)
+	}
+	if r.Regex == "" && r.Literal == "" {
+		return fmt.Errorf("%s without regex or literal", label)
+	}
+	if r.Name == "" || r.unnamed {
+		r.Name = "filter"
+		r.unnamed = true
+	}
+	if r.Replace == "" {
+		r.Replace = "[**" + r.Name + "**]"
+	}
+	if r.Literal != "" {
+		r.literals = []string{r.Literal}
+	}
+	if r.Regex != "" {
+		re, err := regexp.Compile(r.Regex)
+		if err != nil {
+			return fmt.Errorf("%s: %w", label, err)
+		}
+		r.re = re
+	}
+	r.nets, r.prefix = nil, nil
+	for _, allow := range r.Allow {
+		if _, ipnet, err := net.ParseCIDR(allow); err == nil {
+			r.nets = append(r.nets, ipnet)
+		} else {
+			r.prefix = append(r.prefix, allow)
+		}
+	}
+	return nil
+}
+
+// values found on the system, replaced as literals
+func (r *RedactRule) setLiterals(values []string) {
+	r.literals = values
+}
+
+func (r *RedactRule) allowed(value string) bool {
+	for _, prefix := range r.prefix {
+		if strings.HasPrefix(value, prefix) {
+			return true
+		}
+	}
+	if len(r.nets) > 0 {
+		if ip := net.ParseIP(value); ip != nil {
+			for _, ipnet := range r.nets {
+				if ipnet.Contains(ip) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// fixed replacement, or stable token in pseudonymizing mode
+func (r *RedactRule) replacement(value string) string {
+	if pseudoFlag {
+		return pseudonyms.token(r.Name, value)
+	}
+	return r.Replace
+}
+
+// replace values, return new text and count of replacements
+func (r *RedactRule) apply(text string) (string, int) {
+	count := 0
+	if len(r.literals) > 0 {
+		for _, literal := range r.literals {
+			if r.allowed(literal) {
+				continue
+			}
+			if n := strings.Count(text, literal); n > 0 {
+				count += n
+				text = strings.ReplaceAll(text, literal, r.replacement(literal))
+			}
+		}
+		return text, count
+	}
+	text = r.re.ReplaceAllStringFunc(text, func(value string) string {
+		if r.allowed(value) {
+			return value
+		}
+		count++
+		return r.replacement(value)
+	})
+	return text, count
+}
+
+// default rules, compiled once
+func getBuiltinRules() []*RedactRule {
+	builtinRulesOnce.Do(func() {
+		for i := range defaultRules {
+			rule := defaultRules[i]
+			if err := rule.compile(); err == nil {
+				builtinRules = append(builtinRules, &rule)
+			}
+		}
+		if me, err := user.Current(); err == nil && me.Username != "" {
+			rule := RedactRule{Name: "user", Literal: me.Username, Replace: "[**$USER**]"}
+			rule.compile()
+			builtinRules = append(builtinRules, &rule)
+		}
+	})
+	return builtinRules
+}
+
+// values detected on system, only when an output is redacted: not for -l or -lint
+func getDetectedRules() []*RedactRule {
+	detectedRulesOnce.Do(func() {
+		detectedRulesList = detectedRules()
+	})
+	return detectedRulesList
+}
+
+func findRule(rules []*RedactRule, name string) *RedactRule {
+	for _, rule := range rules {
+		if rule.Name == name && !rule.unnamed {
+			return rule
+		}
+	}
+	return nil
+}
+
+// rule replaces a previous rule of same name, else is added
+func addRule(rules []*RedactRule, rule *RedactRule) []*RedactRule {
+	for i := range rules {
+		if rules[i].Name == rule.Name && !rule.unnamed && !rules[i].unnamed {
+			rules[i] = rule
+			return rules
+		}
+	}
+	return append(rules, rule)
+}
+
+// compile rules of service and actions, actions get builtin + service + own rules
+func (s *Service) compileRules() error {
+	serviceRules := append([]*RedactRule{}, getBuiltinRules()...)
+	for i := range s.Redact {
+		if err := s.Redact[i].compile(); err != nil {
+			return err
+		}
+		serviceRules = addRule(serviceRules, &s.Redact[i])
+	}
+	for id := range s.Actions {
+		a := &s.Actions[id]
+		a.rules = append([]*RedactRule{}, serviceRules...)
+		for i := range a.Redact {
+			if err := a.Redact[i].compile(); err != nil {
+				return fmt.Errorf("action \"%s\": %w", a.Name, err)
+			}
+			a.rules = addRule(a.rules, &a.Redact[i])
+		}
+	}
+	return nil
+}
+
+// remove private datas, a.Redacted is incremented for each value replaced
+func (a *Action) redact(text string) string {
+	if text == "" {
+		return text
+	}
+	rules := a.rules
+	if rules == nil {
+		rules = getBuiltinRules()
+	}
+	// detected first, unless replaced by a rule of same name
+	detected := []*RedactRule{}
+	for _, rule := range getDetectedRules() {
+		if findRule(rules, rule.Name) == nil {
+			detected = append(detected, rule)
+		}
+	}
+	for _, rule := range append(detected, rules...) {
+		var count int
+		text, count = rule.apply(text)
+		if count > 0 {
+			a.Redacted += count
+			redactCountsMu.Lock()
+			redactCounts[rule.Name] += count
+			redactCountsMu.Unlock()
+		}
+	}
+	return text
+}
+
+// copy of counts by rule for this run
+func redactionCounts() map[string]int {
+	redactCountsMu.Lock()
+	defer redactCountsMu.Unlock()
+	ret := make(map[string]int, len(redactCounts))
+	for k, v := range redactCounts {
+		ret[k] = v
+	}
+	return ret
+}
+
+// "ipv4: 3, mac: 1"
+func redactionSummary() string {
+	counts := redactionCounts()
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("%s: %d", name, counts[name])
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,261 @@
+package main
+
+/*
+	log renderers from the results of the run
+	markdown is the log file, others are written beside for forums: -format discourse
+*/
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/acarl005/stripansi"
+)
+
+var (
+	formatFlag string = "" // discourse, bbcode or text
+	limitFlag  int    = 0  // forum post limit, else from format
+)
+
+type Renderer interface {
+	Render(w io.Writer, conf *Service)
+	Extension() string
+	Limit() int // max characters of a post, 0 if none
+}
+
+func RendererFactory(name string) (Renderer, error) {
+	switch name {
+	case "markdown", "md":
+		return &MarkdownRenderer{}, nil
+	case "discourse":
+		return &DiscourseRenderer{}, nil
+	case "bbcode", "phpbb":
+		return &BBCodeRenderer{}, nil
+	case "text", "txt":
+		return &TextRenderer{}, nil
+	}
+	return nil, fmt.Errorf("output format \"%s\" not supported", name)
+}
+
+// render beside log file, warn if too long for a post
+func writeRendered(conf *Service, logfile string, name string) (string, error) {
+	renderer, err := RendererFactory(name)
+	if err != nil {
+		return "", err
+	}
+	var out strings.Builder
+	renderer.Render(&out, conf)
+
+	filename := strings.TrimSuffix(logfile, ".md") + renderer.Extension()
+	if err := os.WriteFile(filename, []byte(out.String()), 0644); err != nil {
+		return "", err
+	}
+	limit := renderer.Limit()
+	if limitFlag > 0 {
+		limit = limitFlag
+	}
+	if size := utf8.RuneCountInString(out.String()); limit > 0 && size > limit {
+		fmt.Fprintf(os.Stderr, "%s: %s is %d characters, more than the forum limit of %d; remove sections or use -s\n",
+			Warning("Warning"), filename, size, limit)
+	}
+	return filename, nil
+}
+
+// reason of a failure, empty if not failed
+func failureNote(action *Action) string {
+	if action.State() != "failed" {
+		return ""
+	}
+	if action.TimedOut {
+		return fmt.Sprintf("timed out after %s", action.Duration.Round(time.Second))
+	}
+	if action.Canceled {
+		return "canceled"
+	}
+	return fmt.Sprintf("exit status %d", action.Status)
+}
+
+// with -root, the output is not from the inspected system
+func hostNote(action *Action) string {
+	if !action.OnHost {
+		return ""
+	}
+	return "from the live host, not " + rootFlag
+}
+
+// ###############
+// markdown, the log file
+// ###############
+
+type MarkdownRenderer struct{}
+
+func (r *MarkdownRenderer) Extension() string { return ".md" }
+func (r *MarkdownRenderer) Limit() int        { return 0 }
+
+func (r *MarkdownRenderer) Render(w io.Writer, conf *Service) {
+	fmt.Fprintf(w, "### %s\n", conf.Caption)
+	displaySummary(w, conf)
+	if redacted := redactionSummary(); redacted != "" {
+		fmt.Fprintf(w, "\nredacted: %s\n", redacted)
+	}
+
+	for i := range conf.Actions {
+		action := &conf.Actions[i]
+		if action.Output != "" {
+			fmt.Fprintf(w, "\n:: %s\n", action.Name)
+			if host := hostNote(action); host != "" {
+				fmt.Fprintf(w, "*%s*\n", host)
+			}
+			// fence on its own line, sections are split on it
+			fmt.Fprintf(w, "```\n%v```\n", ensureNewline(stripansi.Strip(action.Output)))
+		}
+		note := failureNote(action)
+		if note == "" {
+			continue
+		}
+		if action.Output == "" {
+			fmt.Fprintf(w, "\n:: %s\n", action.Name)
+		}
+		fmt.Fprintf(w, "**%s**\n", note)
+		if action.Stderr != "" {
+			fmt.Fprintf(w, "```\n%s```\n", ensureNewline(action.Stderr))
+		}
+	}
+}
+
+// ###############
+// discourse: [details] folded by action
+// ###############
+
+type DiscourseRenderer struct{}
+
+func (r *DiscourseRenderer) Extension() string { return ".discourse.md" }
+func (r *DiscourseRenderer) Limit() int        { return 32000 } // max_post_length default
+
+func (r *DiscourseRenderer) Render(w io.Writer, conf *Service) {
+	fmt.Fprintf(w, "### %s\n", conf.Caption)
+	displaySummary(w, conf)
+	if redacted := redactionSummary(); redacted != "" {
+		fmt.Fprintf(w, "\nredacted: %s\n", redacted)
+	}
+
+	for i := range conf.Actions {
+		action := &conf.Actions[i]
+		note := failureNote(action)
+		if action.Output == "" && note == "" {
+			continue
+		}
+		summary := action.Name
+		if title := action.Titles.GetText(); title != "" {
+			summary += " - " + title
+		}
+		if note != "" {
+			summary += " (" + note + ")"
+		}
+		if host := hostNote(action); host != "" {
+			summary += " (" + host + ")"
+		}
+		fmt.Fprintf(w, "\n[details=\"%s\"]\n", strings.ReplaceAll(summary, "\"", "'"))
+		if action.Output != "" {
+			fmt.Fprintf(w, "```text\n%s```\n", ensureNewline(stripansi.Strip(action.Output)))
+		}
+		if note != "" && action.Stderr != "" {
+			fmt.Fprintf(w, "```text\n%s```\n", ensureNewline(action.Stderr))
+		}
+		fmt.Fprintln(w, "[/details]")
+	}
+}
+
+// ###############
+// bbcode, phpBB: [spoiler] and [code]
+// ###############
+
+type BBCodeRenderer struct{}
+
+func (r *BBCodeRenderer) Extension() string { return ".bbcode" }
+func (r *BBCodeRenderer) Limit() int        { return 60000 } // max_post_chars default
+
+func (r *BBCodeRenderer) Render(w io.Writer, conf *Service) {
+	fmt.Fprintf(w, "[b]%s[/b]\n\n", conf.Caption)
+	count := make(map[string]int)
+	for i := range conf.Actions {
+		count[conf.Actions[i].State()]++
+	}
+	fmt.Fprintf(w, "succeeded: %d, failed: %d, skipped: %d\n", count["succeeded"], count["failed"], count["skipped"])
+	if redacted := redactionSummary(); redacted != "" {
+		fmt.Fprintf(w, "redacted: %s\n", redacted)
+	}
+
+	for i := range conf.Actions {
+		action := &conf.Actions[i]
+		note := failureNote(action)
+		if action.Output == "" && note == "" {
+			continue
+		}
+		summary := action.Name
+		if note != "" {
+			summary += " (" + note + ")"
+		}
+		if host := hostNote(action); host != "" {
+			summary += " (" + host + ")"
+		}
+		fmt.Fprintf(w, "\n[spoiler=%s]\n", strings.NewReplacer("[", "(", "]", ")").Replace(summary))
+		if action.Output != "" {
+			fmt.Fprintf(w, "[code]%s[/code]\n", bbcodeEscape(stripansi.Strip(action.Output)))
+		}
+		if note != "" && action.Stderr != "" {
+			fmt.Fprintf(w, "[code]%s[/code]\n", bbcodeEscape(action.Stderr))
+		}
+		fmt.Fprintln(w, "[/spoiler]")
+	}
+}
+
+// a "[/code]" in output would close the block
+func bbcodeEscape(text string) string {
+	return strings.ReplaceAll(text, "[/code]", "[ /code]")
+}
+
+// ###############
+// plain text
+// ###############
+
+type TextRenderer struct{}
+
+func (r *TextRenderer) Extension() string { return ".txt" }
+func (r *TextRenderer) Limit() int        { return 0 }
+
+func (r *TextRenderer) Render(w io.Writer, conf *Service) {
+	fmt.Fprintf(w, "%s\n%s\n\n", conf.Caption, strings.Repeat("=", utf8.RuneCountInString(conf.Caption)))
+	for i := range conf.Actions {
+		action := &conf.Actions[i]
+		reason := action.Skipped
+		if note := failureNote(action); note != "" {
+			reason = note
+		} else if host := hostNote(action); host != "" {
+			reason = host
+		}
+		fmt.Fprintf(w, "%-30s %-10s %s\n", action.Name, action.State(), reason)
+	}
+	if redacted := redactionSummary(); redacted != "" {
+		fmt.Fprintf(w, "\nredacted: %s\n", redacted)
+	}
+
+	for i := range conf.Actions {
+		action := &conf.Actions[i]
+		note := failureNote(action)
+		if action.Output == "" && note == "" {
+			continue
+		}
+		fmt.Fprintf(w, "\n--- %s\n", action.Name)
+		if note != "" {
+			fmt.Fprintf(w, "(%s)\n", note)
+		}
+		fmt.Fprint(w, stripansi.Strip(action.Output))
+		if note != "" {
+			fmt.Fprint(w, action.Stderr)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+/*
+	machine-readable report, written alongside the markdown log
+*/
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+type ServiceReport struct {
+	Caption string    `json:"caption"`
+	Version string    `json:"version"`
+	Command string    `json:"config"`
+	Date    time.Time `json:"date"`
+	// count of values replaced by redaction rule
+	Redactions map[string]int `json:"redactions,omitempty"`
+	Actions    []ActionReport `json:"actions,omitempty"`
+}
+
+type ActionReport struct {
+	Name     string          `json:"name"`
+	Title    string          `json:"title,omitempty"`
+	Command  string          `json:"command,omitempty"`
+	Object   string          `json:"object,omitempty"`
+	Requires []RequireResult `json:"requires,omitempty"`
+	State    string          `json:"state"`
+	Skipped  string          `json:"skip_reason,omitempty"`
+	Status   int             `json:"exit_status"`
+	TimedOut bool            `json:"timed_out"`
+	Canceled bool            `json:"canceled,omitempty"`
+	OnHost   bool            `json:"on_host,omitempty"`
+	Duration float64         `json:"duration"` // seconds
+	Redacted int             `json:"redacted"`
+	Elided   int             `json:"elided_lines,omitempty"`
+	Output   string          `json:"output"`
+	Stderr   string          `json:"stderr,omitempty"`
+	// files in bundle
+	Attachments []string `json:"attachments,omitempty"`
+}
+
+func newServiceReport(conf *Service) ServiceReport {
+	return ServiceReport{
+		Caption: conf.Caption,
+		Version: conf.Version,
+		Command: conf.Command,
+		Date:    time.Now(),
+
+		Redactions: redactionCounts(),
+	}
+}
+
+func newActionReport(a *Action) ActionReport {
+	attachments := []string{}
+	for _, at := range a.Attachments {
+		attachments = append(attachments, at.Name())
+	}
+	if len(attachments) < 1 {
+		attachments = nil
+	}
+	return ActionReport{
+		Name:     a.Name,
+		Title:    a.Titles.GetText(),
+		Command:  a.Command,
+		Object:   a.Object,
+		Requires: a.Checks,
+		State:    a.State(),
+		Skipped:  a.Skipped,
+		Status:   a.Status,
+		TimedOut: a.TimedOut,
+		Canceled: a.Canceled,
+		OnHost:   a.OnHost,
+		Duration: a.Duration.Seconds(),
+		Redacted: a.Redacted,
+		Elided:   a.Elided,
+		Output:   a.Output,
+		Stderr:   a.Stderr,
+
+		Attachments: attachments,
+	}
+}
+
+// report filename is the log filename with the format as extension
+func reportFilename(logfile string, format string) string {
+	return strings.TrimSuffix(logfile, ".md") + "." + format
+}
+
+// write report as "json" (one document) or "ndjson" (service line then one line by action)
+func writeReport(conf *Service, logfile string, format string) (string, error) {
+	if format != "json" && format != "ndjson" {
+		return "", fmt.Errorf("unknown report format \"%s\"", format)
+	}
+	filename := reportFilename(logfile, format)
+	f, err := os.Create(filename)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return filename, encodeReport(f, conf, format)
+}
+
+func encodeReport(w io.Writer, conf *Service, format string) error {
+	report := newServiceReport(conf)
+	enc := json.NewEncoder(w)
+	if format == "ndjson" {
+		if err := enc.Encode(report); err != nil {
+			return err
+		}
+		for i := range conf.Actions {
+			if err := enc.Encode(newActionReport(&conf.Actions[i])); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for i := range conf.Actions {
+		report.Actions = append(report.Actions, newActionReport(&conf.Actions[i]))
+	}
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
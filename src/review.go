@@ -0,0 +1,143 @@
+package main
+
+/*
+	review log before upload: drop sections, redact more strings
+*/
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// already redacted: "[**ipv4**]" or "[ipv4-1]"
+var redactedRegex = regexp.MustCompile(`\[\*\*[^\]]+\*\*\]|\[[a-z0-9-]+-\d+\]`)
+
+// strings that can be private, only shown to the user
+var candidateRegexes = []*regexp.Regexp{
+	regexp.MustCompile(`[\w.+-]+@[\w-]+\.[\w.-]+`),                                       // email
+	regexp.MustCompile(`(?i)(password|passwd|token|secret|apikey|api_key)\s*[=:]\s*\S+`), // credentials
+	regexp.MustCompile(`\b[0-9a-fA-F]{16,}\b`),                                           // keys, ids
+}
+
+type LogSection struct {
+	Name string
+	Text string
+}
+
+// split markdown log on ":: name" lines, first section is the header
+// output in ``` blocks can have such lines, as pacman ":: Synchronizing package databases..."
+func splitSections(text string) []LogSection {
+	sections := []LogSection{{Name: "header"}}
+	fenced := false
+	for _, line := range strings.SplitAfter(text, "\n") {
+		if strings.HasPrefix(line, "```") {
+			fenced = !fenced
+		} else if !fenced && strings.HasPrefix(line, ":: ") {
+			sections = append(sections, LogSection{Name: strings.TrimSpace(line[3:])})
+		}
+		sections[len(sections)-1].Text += line
+	}
+	return sections
+}
+
+// colors: redactions in Info, candidates in Warning
+func highlight(text string) string {
+	text = redactedRegex.ReplaceAllStringFunc(text, func(s string) string { return Info(s) })
+	for _, re := range candidateRegexes {
+		text = re.ReplaceAllStringFunc(text, func(s string) string {
+			if redactedRegex.MatchString(s) {
+				return s
+			}
+			return Warning(s)
+		})
+	}
+	return text
+}
+
+func countCandidates(text string) int {
+	count := 0
+	for _, re := range candidateRegexes {
+		for _, s := range re.FindAllString(text, -1) {
+			if !redactedRegex.MatchString(s) {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// interactive review, file is rewritten; false if user quits, Ctrl-C or end of input
+func reviewLog(ctx context.Context, logfile string) bool {
+	data, err := os.ReadFile(logfile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", Danger("Error"), err)
+		return false
+	}
+	sections := splitSections(string(data))
+	extra := []string{}
+	ask := func(prompt string) (string, error) {
+		fmt.Print(prompt)
+		return readAnswer(ctx)
+	}
+	apply := func(text string) string {
+		for _, s := range extra {
+			text = strings.ReplaceAll(text, s, "[**redacted**]")
+		}
+		return text
+	}
+
+	kept := []LogSection{}
+	keepAll := false
+	for i, section := range sections {
+		if keepAll {
+			kept = append(kept, section)
+			continue
+		}
+		for {
+			text := apply(section.Text)
+			fmt.Printf("\n%s %s (%d/%d)", Primary("##"), Hilite(section.Name), i+1, len(sections))
+			if n := countCandidates(text); n > 0 {
+				fmt.Printf(" %s", Warning(fmt.Sprintf("%d string(s) to check", n)))
+			}
+			fmt.Printf("\n%s\n", highlight(text))
+			choice, err := ask("[K]eep, (d)rop, (r)edact a string, keep (a)ll, (q)uit ? ")
+			if err != nil {
+				return false
+			}
+			switch strings.ToLower(choice) {
+			case "d":
+			case "r":
+				s, err := ask("String to redact in all the log: ")
+				if err != nil {
+					return false
+				}
+				if s != "" {
+					extra = append(extra, s)
+				}
+				continue
+			case "a":
+				keepAll = true
+				kept = append(kept, section)
+			case "q":
+				return false
+			default:
+				kept = append(kept, section)
+			}
+			break
+		}
+	}
+
+	f, err := os.Create(logfile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", Danger("Error"), err)
+		return false
+	}
+	defer f.Close()
+	for _, section := range kept {
+		fmt.Fprint(f, apply(section.Text))
+	}
+	fmt.Printf("\n%d/%d section(s) kept, %d string(s) redacted in %s\n", len(kept), len(sections), len(extra), Primary(logfile))
+	return true
+}
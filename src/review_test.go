@@ -0,0 +1,42 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitSections(t *testing.T) {
+	log := "### pacman\n" +
+		"\n:: mirrors\n```\nServer = https://example.org\n```\n" +
+		"\n:: upgrade\n```\n:: Synchronizing package databases...\n:: Starting full system upgrade...\n```\n" +
+		"\n:: failed\n**exit status 1**\n```\n:: error\n```\n"
+	want := []LogSection{
+		{"header", "### pacman\n\n"},
+		{"mirrors", ":: mirrors\n```\nServer = https://example.org\n```\n\n"},
+		{"upgrade", ":: upgrade\n```\n:: Synchronizing package databases...\n:: Starting full system upgrade...\n```\n\n"},
+		{"failed", ":: failed\n**exit status 1**\n```\n:: error\n```\n"},
+	}
+	got := splitSections(log)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestSplitRenderedSections(t *testing.T) {
+	// outputs without final newline: fences stay on their own line
+	conf := &Service{Caption: "log", Actions: []Action{
+		{Name: "one", Output: "no newline"},
+		{Name: "two", Output: "no newline either", Status: 1, Stderr: "err"},
+		{Name: "three", Output: "last\n"},
+	}}
+	var b strings.Builder
+	(&MarkdownRenderer{}).Render(&b, conf)
+	names := []string{}
+	for _, section := range splitSections(b.String()) {
+		names = append(names, section.Name)
+	}
+	if got := strings.Join(names, " "); got != "header one two three" {
+		t.Errorf("sections %q in:\n%s", got, b.String())
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+/*
+	history of runs by config, as json reports, and diff between two runs
+
+	$XDG_STATE_HOME/makelogs/runs/<config>/<timestamp>.json
+	makelogs diff wifi                 last two runs of wifi
+	makelogs diff -l wifi              list runs
+	makelogs diff old.json new.json
+*/
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// runs kept by config
+const RUNS_MAX = 20
+
+func runsDir(config string) string {
+	return filepath.Join(stateDir(), "runs", config)
+}
+
+// save report of this run, remove the oldest
+func saveRun(conf *Service) error {
+	dir := runsDir(conf.Command)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	// nanoseconds: runs in the same second are not replaced, and sort in order
+	f, err := os.Create(filepath.Join(dir, time.Now().Format("20060102-150405.000000000")+".json"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := encodeReport(f, conf, "json"); err != nil {
+		return err
+	}
+	runs := listRuns(conf.Command)
+	for len(runs) > RUNS_MAX {
+		os.Remove(runs[0])
+		runs = runs[1:]
+	}
+	return nil
+}
+
+// reports of config, oldest first
+func listRuns(config string) []string {
+	matches, _ := filepath.Glob(filepath.Join(runsDir(config), "*.json"))
+	sort.Strings(matches)
+	return matches
+}
+
+func loadRun(filename string) (*ServiceReport, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	report := &ServiceReport{}
+	if err := json.Unmarshal(data, report); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return report, nil
+}
+
+type ActionDiff struct {
+	Name  string
+	Kind  string // "added", "removed" or "changed"
+	Old   *ActionReport
+	New   *ActionReport
+	State string // "succeeded -> failed"
+}
+
+// actions by name, in order of the last run then removed ones
+func diffRuns(before *ServiceReport, after *ServiceReport) []ActionDiff {
+	olds := make(map[string]*ActionReport)
+	for i := range before.Actions {
+		olds[before.Actions[i].Name] = &before.Actions[i]
+	}
+	seen := make(map[string]bool)
+	diffs := []ActionDiff{}
+	for i := range after.Actions {
+		a := &after.Actions[i]
+		seen[a.Name] = true
+		o, ok := olds[a.Name]
+		if !ok {
+			diffs = append(diffs, ActionDiff{Name: a.Name, Kind: "added", New: a})
+			continue
+		}
+		d := ActionDiff{Name: a.Name, Kind: "changed", Old: o, New: a}
+		if o.State != a.State || o.Status != a.Status {
+			d.State = fmt.Sprintf("%s (%d) -> %s (%d)", o.State, o.Status, a.State, a.Status)
+		}
+		if d.State != "" || !sameLines(o.Output, a.Output) || !sameLines(o.Stderr, a.Stderr) {
+			diffs = append(diffs, d)
+		}
+	}
+	for i := range before.Actions {
+		if !seen[before.Actions[i].Name] {
+			diffs = append(diffs, ActionDiff{Name: before.Actions[i].Name, Kind: "removed", Old: &before.Actions[i]})
+		}
+	}
+	return diffs
+}
+
+// markdown or terminal, sections ":: name" as in logs
+func writeRunDiff(w io.Writer, before *ServiceReport, after *ServiceReport, diffs []ActionDiff, context int, color bool) {
+	fence, end := "```diff\n", "```\n"
+	if color {
+		fence, end = "", ""
+	}
+	fmt.Fprintf(w, "### diff %s\n\n%s -> %s, %d action(s) changed\n",
+		after.Command, before.Date.Format("2006-01-02 15:04"), after.Date.Format("2006-01-02 15:04"), len(diffs))
+	for _, d := range diffs {
+		name := d.Name
+		if color {
+			name = Primary(name)
+		}
+		fmt.Fprintf(w, "\n:: %s (%s)\n", name, d.Kind)
+		if d.State != "" {
+			fmt.Fprintf(w, "state: %s\n", d.State)
+		}
+		switch d.Kind {
+		case "added":
+			fmt.Fprint(w, fence)
+			printLines(w, "+", d.New.Output, color)
+			fmt.Fprint(w, end)
+		case "removed":
+			fmt.Fprint(w, fence)
+			printLines(w, "-", d.Old.Output, color)
+			fmt.Fprint(w, end)
+		default:
+			if !sameLines(d.Old.Output, d.New.Output) {
+				fmt.Fprint(w, fence)
+				printDiff(w, strings.TrimSuffix(d.Old.Output, "\n"), strings.TrimSuffix(d.New.Output, "\n"), context, color)
+				fmt.Fprint(w, end)
+			}
+			if !sameLines(d.Old.Stderr, d.New.Stderr) {
+				fmt.Fprintln(w, "stderr:")
+				fmt.Fprint(w, fence)
+				printDiff(w, strings.TrimSuffix(d.Old.Stderr, "\n"), strings.TrimSuffix(d.New.Stderr, "\n"), context, color)
+				fmt.Fprint(w, end)
+			}
+		}
+	}
+}
+
+// all lines of an added or removed action
+func printLines(w io.Writer, prefix string, text string, color bool) {
+	if text == "" {
+		return
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(text, "\n"), "\n") {
+		line = prefix + line
+		if color && prefix == "-" {
+			line = Warning(line)
+		} else if color {
+			line = Primary(line)
+		}
+		fmt.Fprintln(w, line)
+	}
+}
+
+func diffCommand(args []string) int {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	listFlag := flags.Bool("l", false, "List runs of config")
+	mdFlag := flags.String("md", "", "Markdown diff file (default in $XDG_STATE_HOME/makelogs/)")
+	contextFlag := flags.Int("c", 3, "Lines of context")
+	flags.Parse(args)
+	args = flags.Args()
+
+	fail := func(format string, a ...interface{}) int {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", Danger("Error"), fmt.Sprintf(format, a...))
+		return 1
+	}
+
+	var files []string
+	switch {
+	case len(args) == 1 && *listFlag:
+		for i, run := range listRuns(args[0]) {
+			fmt.Printf("%3d  %s\n", i+1, run)
+		}
+		return 0
+	case len(args) == 1:
+		files = listRuns(args[0])
+		if len(files) < 2 {
+			return fail("less than 2 runs of \"%s\"", args[0])
+		}
+		files = files[len(files)-2:]
+	case len(args) == 2:
+		files = args
+	default:
+		return fail("usage: diff [-l] config | diff old.json new.json")
+	}
+
+	before, err := loadRun(files[0])
+	if err != nil {
+		return fail("%s", err)
+	}
+	after, err := loadRun(files[1])
+	if err != nil {
+		return fail("%s", err)
+	}
+	diffs := diffRuns(before, after)
+	writeRunDiff(os.Stdout, before, after, diffs, *contextFlag, true)
+
+	filename := *mdFlag
+	if filename == "" {
+		filename = filepath.Join(stateDir(), fmt.Sprintf("%s-diff-%s.md", after.Command, time.Now().Format("20060102-150405.000")))
+	}
+	f, err := os.Create(filename)
+	if err != nil {
+		return fail("%s", err)
+	}
+	defer f.Close()
+	writeRunDiff(f, before, after, diffs, *contextFlag, false)
+	fmt.Printf("\nDiff file : %s (send with -s)\n", Primary(filename))
+	return 0
+}
@@ -0,0 +1,365 @@
+package main
+
+/*
+	receiver for "-s" uploads, to self-host logs instead of public pastebins
+
+	makelogs serve -listen :8080 -dir /var/lib/makelogs -expire 168h
+
+	POST /                upload, multipart field "file" (or raw body), optional field "expire" as "24h"
+	                      response is the url, delete token in header X-Token
+	POST /<id>            with fields "token" and "delete": remove report
+	GET  /                index of reports, only with -index: lists all reports without auth
+	GET  /<id>            report as html, by sections
+	GET  /<id>.md         raw report
+
+	client config, <config dir>/paste/backends.yaml:
+	order: ["company"]
+	backends:
+	  - name: "company"
+	    url: "https://logs.example.org/"
+	    token_header: "X-Token"
+*/
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	SERVE_EXPIRE  = 7 * 24 * time.Hour
+	SERVE_MAXSIZE = 10 << 20
+)
+
+var reportIDRegex = regexp.MustCompile(`^[1-9A-HJ-NP-Za-km-z]{8,16}$`)
+
+type StoredReport struct {
+	ID      string    `json:"id"`
+	Name    string    `json:"name"`
+	Size    int       `json:"size"`
+	Created time.Time `json:"created"`
+	Expires time.Time `json:"expires"`
+	Token   string    `json:"token"`
+}
+
+type ReportServer struct {
+	Dir     string
+	URL     string // public url, else from request
+	Expire  time.Duration
+	MaxSize int64
+	Index   bool
+}
+
+func serveCommand(ctx context.Context, args []string) int {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	listen := flags.String("listen", ":8080", "Address to listen")
+	server := ReportServer{}
+	flags.StringVar(&server.Dir, "dir", "/var/lib/makelogs", "Directory of reports")
+	flags.StringVar(&server.URL, "url", "", "Public url, as https://logs.example.org/")
+	flags.DurationVar(&server.Expire, "expire", SERVE_EXPIRE, "Max life of a report")
+	flags.Int64Var(&server.MaxSize, "maxsize", SERVE_MAXSIZE, "Max size of a report in bytes")
+	flags.BoolVar(&server.Index, "index", false, "List reports on /, readable by anyone")
+	flags.Parse(args)
+
+	if err := os.MkdirAll(server.Dir, 0700); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", Danger("Error"), err)
+		return 1
+	}
+	if server.URL != "" && !strings.HasSuffix(server.URL, "/") {
+		server.URL += "/"
+	}
+
+	httpServer := &http.Server{Addr: *listen, Handler: &server}
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		httpServer.Shutdown(shutdown)
+	}()
+	go server.cleanLoop(ctx)
+
+	log.Printf("serve %s on %s", Primary(server.Dir), Primary(*listen))
+	if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", Danger("Error"), err)
+		return 1
+	}
+	return 0
+}
+
+func (s *ReportServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	switch {
+	case path == "" && r.Method == http.MethodPost:
+		s.upload(w, r)
+	case path == "" && r.Method == http.MethodGet:
+		if !s.Index {
+			http.NotFound(w, r)
+			return
+		}
+		s.index(w)
+	case r.Method == http.MethodPost:
+		s.delete(w, r, path)
+	case r.Method == http.MethodGet && strings.HasSuffix(path, ".md"):
+		s.raw(w, r, strings.TrimSuffix(path, ".md"))
+	case r.Method == http.MethodGet:
+		s.show(w, r, path)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// ###############
+// storage: <id>.md and <id>.json
+// ###############
+
+func newReportID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base58Encode(b), nil
+}
+
+func (s *ReportServer) load(id string) (*StoredReport, error) {
+	if !reportIDRegex.MatchString(id) {
+		return nil, os.ErrNotExist
+	}
+	data, err := os.ReadFile(filepath.Join(s.Dir, id+".json"))
+	if err != nil {
+		return nil, err
+	}
+	report := &StoredReport{}
+	if err := json.Unmarshal(data, report); err != nil {
+		return nil, err
+	}
+	if time.Now().After(report.Expires) {
+		s.remove(id)
+		return nil, os.ErrNotExist
+	}
+	return report, nil
+}
+
+func (s *ReportServer) remove(id string) {
+	os.Remove(filepath.Join(s.Dir, id+".md"))
+	os.Remove(filepath.Join(s.Dir, id+".json"))
+}
+
+func (s *ReportServer) list() []StoredReport {
+	matches, _ := filepath.Glob(filepath.Join(s.Dir, "*.json"))
+	reports := []StoredReport{}
+	for _, match := range matches {
+		if report, err := s.load(strings.TrimSuffix(filepath.Base(match), ".json")); err == nil {
+			reports = append(reports, *report)
+		}
+	}
+	sort.Slice(reports, func(i, j int) bool { return reports[i].Created.After(reports[j].Created) })
+	return reports
+}
+
+// load() removes expired reports
+func (s *ReportServer) cleanLoop(ctx context.Context) {
+	ticker := time.NewTicker(10 * time.Minute)
+	defer ticker.Stop()
+	for {
+		s.list()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *ReportServer) baseURL(r *http.Request) string {
+	if s.URL != "" {
+		return s.URL
+	}
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + "/"
+}
+
+// ###############
+// handlers
+// ###############
+
+func (s *ReportServer) upload(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.MaxSize+1<<16)
+	var content []byte
+	name := ""
+	expire := s.Expire
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, "field \"file\" not found", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		name = filepath.Base(header.Filename)
+		if content, err = io.ReadAll(io.LimitReader(file, s.MaxSize+1)); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if value := r.FormValue("expire"); value != "" {
+			d, err := time.ParseDuration(value)
+			if err != nil || d <= 0 {
+				http.Error(w, "bad expire", http.StatusBadRequest)
+				return
+			}
+			if d < expire {
+				expire = d
+			}
+		}
+	} else {
+		var err error
+		if content, err = io.ReadAll(io.LimitReader(r.Body, s.MaxSize+1)); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if len(content) == 0 {
+		http.Error(w, "empty report", http.StatusBadRequest)
+		return
+	}
+	if int64(len(content)) > s.MaxSize {
+		http.Error(w, "report too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	id, err := newReportID()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	token := make([]byte, 16)
+	rand.Read(token)
+	report := StoredReport{
+		ID:      id,
+		Name:    name,
+		Size:    len(content),
+		Created: time.Now(),
+		Expires: time.Now().Add(expire),
+		Token:   hex.EncodeToString(token),
+	}
+	meta, _ := json.Marshal(report)
+	if err := os.WriteFile(filepath.Join(s.Dir, id+".md"), content, 0600); err != nil {
+		http.Error(w, "can not save report", http.StatusInternalServerError)
+		log.Println(err)
+		return
+	}
+	if err := os.WriteFile(filepath.Join(s.Dir, id+".json"), meta, 0600); err != nil {
+		s.remove(id)
+		http.Error(w, "can not save report", http.StatusInternalServerError)
+		log.Println(err)
+		return
+	}
+	log.Printf("new report %s (%d bytes) from %s", id, len(content), r.RemoteAddr)
+	w.Header().Set("X-Token", report.Token)
+	w.Header().Set("X-Expires", report.Expires.Format(time.RFC3339))
+	fmt.Fprintln(w, s.baseURL(r)+id)
+}
+
+func (s *ReportServer) delete(w http.ResponseWriter, r *http.Request, id string) {
+	report, err := s.load(id)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	token := r.FormValue("token")
+	if _, ok := r.Form["delete"]; !ok || subtle.ConstantTimeCompare([]byte(token), []byte(report.Token)) != 1 {
+		http.Error(w, "bad token", http.StatusForbidden)
+		return
+	}
+	s.remove(id)
+	log.Printf("report %s deleted", id)
+	fmt.Fprintln(w, "deleted")
+}
+
+func (s *ReportServer) raw(w http.ResponseWriter, r *http.Request, id string) {
+	if _, err := s.load(id); err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	http.ServeFile(w, r, filepath.Join(s.Dir, id+".md"))
+}
+
+func (s *ReportServer) show(w http.ResponseWriter, r *http.Request, id string) {
+	report, err := s.load(id)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	data, err := os.ReadFile(filepath.Join(s.Dir, id+".md"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	reportTemplate.Execute(w, struct {
+		Report   *StoredReport
+		Sections []LogSection
+		Index    bool
+	}{report, splitSections(string(data)), s.Index})
+}
+
+func (s *ReportServer) index(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	indexTemplate.Execute(w, s.list())
+}
+
+// ###############
+// html
+// ###############
+
+const serveStyle = `<style>
+body{font-family:sans-serif;max-width:70em;margin:auto;padding:1em}
+pre{background:#f4f4f4;padding:.5em;overflow-x:auto}
+summary{cursor:pointer;font-weight:bold;padding:.3em 0}
+table{border-collapse:collapse}td,th{padding:.2em .8em;border-bottom:1px solid #ddd;text-align:left}
+</style>`
+
+// section text without ":: name" line and code fences
+func sectionBody(text string) string {
+	lines := []string{}
+	for i, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		if i == 0 || strings.HasPrefix(line, "```") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{"body": sectionBody}).Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>makelogs {{.Report.ID}}</title>` + serveStyle + `</head><body>
+<p>{{if .Index}}<a href="./">index</a> · {{end}}<a href="{{.Report.ID}}.md">raw</a> · expires {{.Report.Expires.Format "2006-01-02 15:04"}}</p>
+{{range $i, $s := .Sections}}{{if eq $i 0}}<pre>{{$s.Text}}</pre>
+{{else}}<details open><summary>{{$s.Name}}</summary><pre>{{body $s.Text}}</pre></details>
+{{end}}{{end}}</body></html>
+`))
+
+var indexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>makelogs reports</title>` + serveStyle + `</head><body>
+<h1>Reports</h1>
+<table><tr><th>id</th><th>name</th><th>size</th><th>date</th><th>expires</th></tr>
+{{range .}}<tr><td><a href="{{.ID}}">{{.ID}}</a></td><td>{{.Name}}</td><td>{{.Size}}</td><td>{{.Created.Format "2006-01-02 15:04"}}</td><td>{{.Expires.Format "2006-01-02 15:04"}}</td></tr>
+{{else}}<tr><td colspan="5">no report</td></tr>
+{{end}}</table></body></html>
+`))
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testReport = "### wifi\n\n:: ip\n```\n<script>alert(1)</script>\n```\n"
+
+func testServer(t *testing.T, index bool) (*ReportServer, *httptest.Server) {
+	t.Helper()
+	rs := &ReportServer{Dir: t.TempDir(), Expire: time.Hour, MaxSize: 1 << 10, Index: index}
+	server := httptest.NewServer(rs)
+	t.Cleanup(server.Close)
+	return rs, server
+}
+
+// multipart upload as 0x0 backend, return url and token
+func testUpload(t *testing.T, server *httptest.Server, content string, fields map[string]string) *http.Response {
+	t.Helper()
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for k, v := range fields {
+		w.WriteField(k, v)
+	}
+	part, _ := w.CreateFormFile("file", "wifi-20260101-120000.md")
+	part.Write([]byte(content))
+	w.Close()
+	resp, err := http.Post(server.URL+"/", w.FormDataContentType(), &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func readBody(t *testing.T, resp *http.Response) string {
+	t.Helper()
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestServeUploadAndFetch(t *testing.T) {
+	_, server := testServer(t, false)
+
+	resp := testUpload(t, server, testReport, nil)
+	link := strings.TrimSpace(readBody(t, resp))
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(link, server.URL+"/") {
+		t.Fatalf("upload: %s %q", resp.Status, link)
+	}
+	if resp.Header.Get("X-Token") == "" {
+		t.Error("upload: no delete token")
+	}
+	id := strings.TrimPrefix(link, server.URL+"/")
+	if !reportIDRegex.MatchString(id) {
+		t.Errorf("bad id %q", id)
+	}
+
+	resp, _ = http.Get(link + ".md")
+	if body := readBody(t, resp); resp.StatusCode != http.StatusOK || body != testReport {
+		t.Errorf("raw: %s %q", resp.Status, body)
+	}
+
+	resp, _ = http.Get(link)
+	body := readBody(t, resp)
+	if resp.StatusCode != http.StatusOK || !strings.Contains(body, "<summary>ip</summary>") {
+		t.Errorf("html: %s %q", resp.Status, body)
+	}
+	if strings.Contains(body, "<script>") || !strings.Contains(body, "&lt;script&gt;") {
+		t.Errorf("html: output not escaped: %q", body)
+	}
+	if strings.Contains(body, `href="./"`) {
+		t.Error("html: link to disabled index")
+	}
+}
+
+func TestServeRawUpload(t *testing.T) {
+	_, server := testServer(t, false)
+	resp, err := http.Post(server.URL+"/", "text/plain", strings.NewReader(testReport))
+	if err != nil {
+		t.Fatal(err)
+	}
+	link := strings.TrimSpace(readBody(t, resp))
+	resp, _ = http.Get(link + ".md")
+	if body := readBody(t, resp); body != testReport {
+		t.Errorf("raw: %q", body)
+	}
+}
+
+func TestServeUploadRefused(t *testing.T) {
+	_, server := testServer(t, false)
+	tests := []struct {
+		name    string
+		content string
+		fields  map[string]string
+		status  int
+	}{
+		{"empty", "", nil, http.StatusBadRequest},
+		{"too large", strings.Repeat("x", 2<<10), nil, http.StatusRequestEntityTooLarge},
+		{"bad expire", testReport, map[string]string{"expire": "soon"}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		resp := testUpload(t, server, tt.content, tt.fields)
+		readBody(t, resp)
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s: got %s, want %d", tt.name, resp.Status, tt.status)
+		}
+	}
+}
+
+func TestServeIndex(t *testing.T) {
+	tests := []struct {
+		index  bool
+		status int
+	}{
+		{false, http.StatusNotFound}, // default: reports are private
+		{true, http.StatusOK},
+	}
+	for _, tt := range tests {
+		_, server := testServer(t, tt.index)
+		link := strings.TrimSpace(readBody(t, testUpload(t, server, testReport, nil)))
+		id := strings.TrimPrefix(link, server.URL+"/")
+
+		resp, _ := http.Get(server.URL + "/")
+		body := readBody(t, resp)
+		if resp.StatusCode != tt.status {
+			t.Errorf("index %v: got %s, want %d", tt.index, resp.Status, tt.status)
+		}
+		if listed := strings.Contains(body, id); listed != tt.index {
+			t.Errorf("index %v: report listed %v", tt.index, listed)
+		}
+	}
+}
+
+func TestServeDelete(t *testing.T) {
+	_, server := testServer(t, false)
+	resp := testUpload(t, server, testReport, nil)
+	link := strings.TrimSpace(readBody(t, resp))
+	token := resp.Header.Get("X-Token")
+
+	for _, tt := range []struct {
+		token  string
+		status int
+	}{
+		{"bad", http.StatusForbidden},
+		{token, http.StatusOK},
+		{token, http.StatusNotFound}, // already deleted
+	} {
+		resp, _ := http.PostForm(link, url.Values{"token": {tt.token}, "delete": {""}})
+		readBody(t, resp)
+		if resp.StatusCode != tt.status {
+			t.Errorf("delete with %q: got %s, want %d", tt.token, resp.Status, tt.status)
+		}
+	}
+	resp, _ = http.Get(link + ".md")
+	if readBody(t, resp); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("deleted report: %s", resp.Status)
+	}
+}
+
+func TestServeExpired(t *testing.T) {
+	rs, server := testServer(t, false)
+	link := strings.TrimSpace(readBody(t, testUpload(t, server, testReport, nil)))
+	id := strings.TrimPrefix(link, server.URL+"/")
+
+	// expire it
+	meta := filepath.Join(rs.Dir, id+".json")
+	report := StoredReport{}
+	data, _ := os.ReadFile(meta)
+	json.Unmarshal(data, &report)
+	report.Expires = time.Now().Add(-time.Minute)
+	data, _ = json.Marshal(report)
+	os.WriteFile(meta, data, 0600)
+
+	resp, _ := http.Get(link)
+	if readBody(t, resp); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expired report: %s", resp.Status)
+	}
+	if _, err := os.Stat(filepath.Join(rs.Dir, id+".md")); err == nil {
+		t.Error("expired report not removed")
+	}
+}
+
+func TestServeBadID(t *testing.T) {
+	_, server := testServer(t, true)
+	for _, path := range []string{"/..%2f..%2fetc%2fpasswd", "/short", "/0OIl0OIl0OIl"} {
+		resp, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if readBody(t, resp); resp.StatusCode != http.StatusNotFound {
+			t.Errorf("%s: %s", path, resp.Status)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+/*
+	collect logs from a mounted system (live usb): --root /mnt
+*/
+import (
+	"os/exec"
+	"path/filepath"
+	"sync"
+)
+
+var rootFlag string = "" // empty for running system
+
+var (
+	chrootCmd     string
+	chrootCmdOnce sync.Once
+)
+
+// path in the inspected system
+func rootPath(path string) string {
+	if rootFlag == "" {
+		return path
+	}
+	return filepath.Join(rootFlag, path)
+}
+
+// arch-chroot mounts /proc, /sys, /dev ... else use plain chroot
+func getChrootCommand() string {
+	chrootCmdOnce.Do(func() {
+		chrootCmd = "chroot"
+		if _, err := exec.LookPath("arch-chroot"); err == nil {
+			chrootCmd = "arch-chroot"
+		}
+	})
+	return chrootCmd
+}
+
+// script is run inside the mounted system if the action is chroot-safe
+func (a *Action) shell(script string) string {
+	if rootFlag == "" || !a.Chroot {
+		return script
+	}
+	return getChrootCommand() + " " + shellQuote(rootFlag) + " bash -c " + shellQuote(script)
+}
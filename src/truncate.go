@@ -0,0 +1,197 @@
+package main
+
+/*
+	output size limits: "max_lines:", "max_bytes:" by action, "budget:" (bytes) for all actions
+	head and tail are kept, the end of a log is often the useful part
+
+	- name: "inxi"
+	  max_lines: 300
+*/
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var budgetFlag int = 0 // bytes of all outputs, else "budget:" of service
+
+// part of kept lines at the start, the rest is the end
+const HEAD_RATIO = 3
+
+var elidedRegex = regexp.MustCompile(`^\[\.\.\. (\d+) lines elided \.\.\.\]\n$`)
+
+func elidedLine(count int) string {
+	return fmt.Sprintf("[... %d lines elided ...]\n", count)
+}
+
+// lines already counted in a marker of truncateLines, 0 if not a marker
+func elidedCount(line string) int {
+	m := elidedRegex.FindStringSubmatch(line)
+	if m == nil {
+		return 0
+	}
+	count, _ := strconv.Atoi(m[1])
+	return count
+}
+
+func splitLines(text string) []string {
+	return strings.SplitAfter(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// keep head and tail lines, return text and count of lines removed
+func truncateLines(text string, maxLines int) (string, int) {
+	lines := splitLines(text)
+	if maxLines < 1 || len(lines) <= maxLines {
+		return text, 0
+	}
+	head := maxLines / HEAD_RATIO
+	tail := maxLines - head
+	elided := len(lines) - head - tail
+	return strings.Join(lines[:head], "") + elidedLine(elided) + ensureNewline(strings.Join(lines[len(lines)-tail:], "")), elided
+}
+
+// same by size, lines are cut only if the first and last ones are too long
+// count of new lines removed: a marker removed here gives its count to the new one
+func truncateBytes(text string, maxBytes int) (string, int) {
+	if maxBytes < 1 || len(text) <= maxBytes {
+		return text, 0
+	}
+	lines := splitLines(text)
+	limit := maxBytes - len(elidedLine(len(lines))) // room for the marker
+	size := 0
+	head := 0
+	for head < len(lines) && size+len(lines[head]) <= limit/HEAD_RATIO {
+		size += len(lines[head])
+		head++
+	}
+	tail := 0
+	for tail < len(lines)-head && size+len(lines[len(lines)-1-tail]) <= limit {
+		size += len(lines[len(lines)-1-tail])
+		tail++
+	}
+	if head+tail == 0 {
+		return cutBytes(text, maxBytes)
+	}
+	elided, counted := 0, 0
+	for _, line := range lines[head : len(lines)-tail] {
+		if count := elidedCount(line); count > 0 {
+			elided += count
+			counted += count
+		} else {
+			elided++
+		}
+	}
+	return strings.Join(lines[:head], "") + elidedLine(elided) + ensureNewline(strings.Join(lines[len(lines)-tail:], "")), elided - counted
+}
+
+// keep head and tail bytes, at utf-8 boundaries; return count of lines cut or removed
+func cutBytes(text string, maxBytes int) (string, int) {
+	marker := fmt.Sprintf("\n[... %d bytes elided ...]\n", len(text))
+	head := maxBytes / HEAD_RATIO
+	tail := len(text) - (maxBytes - head - len(marker))
+	if tail > len(text) {
+		tail = len(text)
+	}
+	if tail < head {
+		tail = head
+	}
+	for head > 0 && !utf8.RuneStart(text[head]) {
+		head--
+	}
+	for tail < len(text) && !utf8.RuneStart(text[tail]) {
+		tail++
+	}
+	cut := text[head:tail]
+	return text[:head] + fmt.Sprintf("\n[... %d bytes elided ...]\n", len(cut)) + ensureNewline(text[tail:]), strings.Count(cut, "\n") + 1
+}
+
+func omittedLine(count int) string {
+	return fmt.Sprintf("[... %d lines omitted, budget used ...]\n", count)
+}
+
+// whole text replaced by a marker, return count of new lines removed
+func omitText(text string) (string, int) {
+	lines := splitLines(text)
+	omitted, counted := 0, 0
+	for _, line := range lines {
+		if count := elidedCount(line); count > 0 {
+			omitted += count
+			counted += count
+		} else {
+			omitted++
+		}
+	}
+	return omittedLine(omitted), omitted - counted
+}
+
+func ensureNewline(text string) string {
+	if text != "" && !strings.HasSuffix(text, "\n") {
+		return text + "\n"
+	}
+	return text
+}
+
+// apply limits to output and stderr, a.Elided is incremented
+func (a *Action) truncate(maxLines int, maxBytes int) {
+	for _, text := range []*string{&a.Output, &a.Stderr} {
+		var n, m int
+		*text, n = truncateLines(*text, maxLines)
+		*text, m = truncateBytes(*text, maxBytes)
+		a.Elided += n + m
+	}
+}
+
+// bytes for all outputs, -budget else "budget:" of service
+func (s *Service) budget() int {
+	if budgetFlag > 0 {
+		return budgetFlag
+	}
+	return s.Budget
+}
+
+// share budget between outputs and stderrs: small ones are kept, large ones get the same part
+func (s *Service) applyBudget(budget int) {
+	if budget < 1 {
+		return
+	}
+	type part struct {
+		action *Action
+		text   *string
+	}
+	parts := []part{}
+	total := 0
+	for i := range s.Actions {
+		a := &s.Actions[i]
+		for _, text := range []*string{&a.Output, &a.Stderr} {
+			if *text != "" {
+				parts = append(parts, part{a, text})
+				total += len(*text)
+			}
+		}
+	}
+	if total <= budget {
+		return
+	}
+	sort.SliceStable(parts, func(i, j int) bool { return len(*parts[i].text) < len(*parts[j].text) })
+	remaining := budget
+	for k, p := range parts {
+		share := remaining / (len(parts) - k)
+		if len(*p.text) > share {
+			var n int
+			if share < len(omittedLine(0)) {
+				// too small for a head and a tail: budget used
+				*p.text, n = omitText(*p.text)
+			} else {
+				*p.text, n = truncateBytes(*p.text, share)
+			}
+			p.action.Elided += n
+		}
+		remaining -= len(*p.text)
+		if remaining < 0 {
+			remaining = 0
+		}
+	}
+}
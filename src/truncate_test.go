@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func numberedLines(n int) string {
+	var b strings.Builder
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&b, "line %03d\n", i)
+	}
+	return b.String()
+}
+
+func TestTruncateBytes(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		maxBytes int
+		elided   int
+		contains []string
+	}{
+		{"small", "a\nb\n", 10, 0, []string{"a\nb\n"}},
+		{"lines", numberedLines(10), 70, 5, []string{"line 001\n", "[... 5 lines elided ...]\n", "line 010\n"}},
+		{"long line", strings.Repeat("x", 1000) + "\n", 120, 1, []string{"bytes elided ...]\n"}},
+		{"long runes", strings.Repeat("é", 500), 120, 1, []string{"bytes elided ...]\n"}},
+	}
+	for _, tt := range tests {
+		got, elided := truncateBytes(tt.text, tt.maxBytes)
+		if elided != tt.elided {
+			t.Errorf("%s: elided %d, want %d", tt.name, elided, tt.elided)
+		}
+		if len(got) > tt.maxBytes || !utf8.ValidString(got) {
+			t.Errorf("%s: %d bytes, valid utf-8 %v: %q", tt.name, len(got), utf8.ValidString(got), got)
+		}
+		for _, s := range tt.contains {
+			if !strings.Contains(got, s) {
+				t.Errorf("%s: %q not in %q", tt.name, s, got)
+			}
+		}
+	}
+	// the head and tail of a long line are kept
+	got, _ := truncateBytes("start"+strings.Repeat("x", 1000)+"end\n", 120)
+	if !strings.HasPrefix(got, "startx") || !strings.HasSuffix(got, "xend\n") {
+		t.Errorf("long line: %q", got)
+	}
+}
+
+func TestTruncateMarkerCount(t *testing.T) {
+	a := &Action{Output: numberedLines(100)}
+	a.truncate(20, 60)
+	if a.Elided != 100-countOriginal(a.Output) {
+		t.Errorf("elided %d, %d lines kept: %q", a.Elided, countOriginal(a.Output), a.Output)
+	}
+	if !strings.Contains(a.Output, fmt.Sprintf("[... %d lines elided ...]", a.Elided)) {
+		t.Errorf("marker does not give %d: %q", a.Elided, a.Output)
+	}
+}
+
+// lines of numberedLines kept
+func countOriginal(text string) int {
+	return strings.Count(text, "line ")
+}
+
+func TestApplyBudget(t *testing.T) {
+	s := &Service{Actions: []Action{
+		{Name: "small", Output: "ok\n"},
+		{Name: "out", Output: numberedLines(100)},
+		{Name: "err", Stderr: numberedLines(100)},
+	}}
+	s.applyBudget(300)
+	total := 0
+	for _, a := range s.Actions {
+		total += len(a.Output) + len(a.Stderr)
+	}
+	if total > 300 {
+		t.Errorf("%d bytes after budget", total)
+	}
+	if s.Actions[0].Output != "ok\n" || s.Actions[1].Elided == 0 || s.Actions[2].Elided == 0 {
+		t.Errorf("got %+v", s.Actions)
+	}
+}
+
+func TestServiceBudget(t *testing.T) {
+	defer func() { budgetFlag = 0 }()
+	s := &Service{Budget: 1000}
+	if s.budget() != 1000 {
+		t.Errorf("budget %d", s.budget())
+	}
+	budgetFlag = 500
+	if s.budget() != 500 {
+		t.Errorf("-budget: %d", s.budget())
+	}
+}
+
+func TestApplyBudgetTooSmall(t *testing.T) {
+	// budget smaller than the count of outputs: each share is 0
+	s := &Service{}
+	for i := 0; i < 5; i++ {
+		s.Actions = append(s.Actions, Action{Name: fmt.Sprint(i), Output: numberedLines(100)})
+	}
+	s.applyBudget(3)
+	for _, a := range s.Actions {
+		if a.Output != omittedLine(100) || a.Elided != 100 {
+			t.Errorf("%s: elided %d, output %q", a.Name, a.Elided, a.Output)
+		}
+	}
+}
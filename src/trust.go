@@ -0,0 +1,155 @@
+package main
+
+/*
+	configs run as root must be trusted:
+	embedded, or owned by root and only writable by root, or listed in manifest
+	manifest is made by root as: sha256sum my.yaml >> /etc/makelogs/trusted.sha256
+*/
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+const TRUST_MANIFEST = "/etc/makelogs/trusted.sha256"
+
+var trustFlag bool = false
+
+// owned by root and not writable by others
+func rootOwned(path string, info os.FileInfo) error {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok && stat.Uid != 0 {
+		return fmt.Errorf("\"%s\" is owned by uid %d", path, stat.Uid)
+	}
+	if info.Mode().Perm()&0022 != 0 && info.Mode()&os.ModeSticky == 0 {
+		return fmt.Errorf("\"%s\" is writable by group or others", path)
+	}
+	return nil
+}
+
+// file, from stat of the open file, and all its directories up to /
+func rootOnly(filename string, info os.FileInfo) error {
+	if err := rootOwned(filename, info); err != nil {
+		return err
+	}
+	for dir := filepath.Dir(filename); ; dir = filepath.Dir(dir) {
+		dirInfo, err := os.Stat(dir)
+		if err != nil {
+			return err
+		}
+		if err := rootOwned(dir, dirInfo); err != nil {
+			return err
+		}
+		if dir == "/" || dir == "." {
+			return nil
+		}
+	}
+}
+
+// content and stat of the same open file
+func readStat(filename string) ([]byte, os.FileInfo, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := io.ReadAll(file)
+	return data, info, err
+}
+
+func dataSum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// sha256sum format: "<sum>  <path>", manifest is used only if root only
+func readManifest(filename string) map[string]string {
+	sums := make(map[string]string)
+	data, info, err := readStat(filename)
+	if err != nil || rootOnly(filename, info) != nil {
+		return sums
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 {
+			sums[strings.TrimPrefix(fields[1], "*")] = fields[0]
+		}
+	}
+	return sums
+}
+
+// nil if config file can run as root, read is the content parsed and stat of the open file
+func trustFile(filename string, read ConfRead, manifest map[string]string) error {
+	if strings.HasPrefix(filename, EMBEDDED) {
+		return nil
+	}
+	if read.Info == nil {
+		return fmt.Errorf("\"%s\" not read", filename)
+	}
+	err := rootOnly(filename, read.Info)
+	if err == nil {
+		return nil
+	}
+	if sum, ok := manifest[filename]; ok {
+		if dataSum(read.Data) == sum {
+			return nil
+		}
+		return fmt.Errorf("\"%s\" modified since added in %s", filename, TRUST_MANIFEST)
+	}
+	return err
+}
+
+type TrustError struct {
+	File string
+	Data []byte // as parsed
+	Err  error
+}
+
+func (e *TrustError) Error() string {
+	return e.Err.Error()
+}
+
+// as root, first loaded file not trusted, nil if all are
+func (d Directory) checkTrust(loaded loadInfo) *TrustError {
+	if os.Geteuid() != 0 || trustFlag {
+		return nil
+	}
+	manifest := readManifest(TRUST_MANIFEST)
+	for i, filename := range loaded.Files {
+		if err := trustFile(filename, loaded.Read[i], manifest); err != nil {
+			return &TrustError{File: filename, Data: loaded.Read[i].Data, Err: err}
+		}
+	}
+	return nil
+}
+
+// exit before an action of an untrusted config runs, show changes from embedded file
+// listing and lint only mark untrusted configs
+func requireTrust(conf *Service) {
+	for _, action := range conf.Actions {
+		e := action.untrusted
+		if e == nil {
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "%s: untrusted config: %s\n", Danger("Error"), e)
+		embedded := EMBEDDED + confName(e.File) + "." + EXTENSION
+		if confExists(embedded) {
+			orig, _ := readConfFile(embedded)
+			fmt.Fprintf(os.Stderr, "\nChanges from %s:\n", embedded)
+			printDiff(os.Stderr, string(orig), string(e.Data), 2, true)
+		}
+		fmt.Fprintf(os.Stderr, "\nRead this file, then run again with --trust if it is safe\n")
+		os.Exit(3)
+	}
+}